@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/marcos-venicius/lfi/hll"
+	"github.com/marcos-venicius/lfi/sketch"
+	"github.com/marcos-venicius/quang"
+)
+
+// accumulators receive the raw value of the field, the numeric ones
+// ignore values that are not numbers
+type accumulator_t interface {
+	add(value any)
+	result() any
+}
+
+type agg_t struct {
+	// how it was written by the user, like "p95(size)"
+	name     string
+	function string
+	field    string
+	// only for percentiles, between 0 and 1
+	quantile float64
+	// only for count_distinct, never switch to hyperloglog
+	exact bool
+}
+
+var aggRegex = regexp.MustCompile(`^([a-z_]+|p\d+(?:\.\d+)?)\(\s*([a-zA-Z0-9_]*)\s*\)$`)
+
+var percentileRegex = regexp.MustCompile(`^p(\d+(?:\.\d+)?)$`)
+
+// "sum(size), p95(size)" -> [sum(size), p95(size)]
+func parseAggs(list string, configs *Configs, exact bool) ([]agg_t, error) {
+	aggs := make([]agg_t, 0)
+
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+
+		if len(item) == 0 {
+			continue
+		}
+
+		matches := aggRegex.FindStringSubmatch(item)
+
+		if matches == nil {
+			return nil, fmt.Errorf("invalid aggregation \"%s\". expected something like sum(size)", item)
+		}
+
+		agg := agg_t{name: item, function: matches[1], field: matches[2], exact: exact}
+
+		switch {
+		case agg.function == "count":
+			if len(agg.field) > 0 {
+				return nil, fmt.Errorf("count does not take a field, use count()")
+			}
+		case agg.function == "sum", agg.function == "avg", agg.function == "min", agg.function == "max", agg.function == "count_distinct":
+		case percentileRegex.MatchString(agg.function):
+			n, err := strconv.ParseFloat(percentileRegex.FindStringSubmatch(agg.function)[1], 64)
+
+			if err != nil || n < 0 || n > 100 {
+				return nil, fmt.Errorf("invalid percentile \"%s\". it should be between p0 and p100", agg.function)
+			}
+
+			agg.quantile = n / 100
+		default:
+			return nil, fmt.Errorf("unknown aggregation \"%s\"", agg.function)
+		}
+
+		if agg.function != "count" {
+			if len(agg.field) == 0 {
+				return nil, fmt.Errorf("%s needs a field, like %s(size)", agg.function, agg.function)
+			}
+
+			if !isKnownField(agg.field, configs) {
+				return nil, fmt.Errorf("unknown field \"%s\"", agg.field)
+			}
+		}
+
+		aggs = append(aggs, agg)
+	}
+
+	if len(aggs) == 0 {
+		return nil, fmt.Errorf("missing aggregations in \"%s\"", list)
+	}
+
+	return aggs, nil
+}
+
+// numbers, and strings that look like numbers, can be aggregated
+func numberOf(value any) (float64, bool) {
+	switch v := value.(type) {
+	case quang.IntegerType:
+		return float64(v), true
+	case quang.FloatType:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+
+		return n, err == nil
+	}
+
+	return 0, false
+}
+
+type count_t struct{ n int }
+
+func (c *count_t) add(any)     { c.n++ }
+func (c *count_t) result() any { return c.n }
+
+type sum_t struct{ sum float64 }
+
+func (s *sum_t) add(value any) {
+	if n, ok := numberOf(value); ok {
+		s.sum += n
+	}
+}
+
+func (s *sum_t) result() any { return roundResult(s.sum) }
+
+type avg_t struct {
+	sum float64
+	n   int
+}
+
+func (a *avg_t) add(value any) {
+	if n, ok := numberOf(value); ok {
+		a.sum += n
+		a.n++
+	}
+}
+
+func (a *avg_t) result() any {
+	if a.n == 0 {
+		return nil
+	}
+
+	return roundResult(a.sum / float64(a.n))
+}
+
+type extreme_t struct {
+	value float64
+	seen  bool
+	max   bool
+}
+
+func (e *extreme_t) add(value any) {
+	n, ok := numberOf(value)
+
+	if !ok {
+		return
+	}
+
+	if !e.seen || (e.max && n > e.value) || (!e.max && n < e.value) {
+		e.value = n
+		e.seen = true
+	}
+}
+
+func (e *extreme_t) result() any {
+	if !e.seen {
+		return nil
+	}
+
+	return roundResult(e.value)
+}
+
+type percentile_t struct {
+	sketch   *sketch.Sketch
+	quantile float64
+}
+
+func (p *percentile_t) add(value any) {
+	if n, ok := numberOf(value); ok {
+		p.sketch.Add(n)
+	}
+}
+
+func (p *percentile_t) result() any {
+	if p.sketch.Count() == 0 {
+		return nil
+	}
+
+	return roundResult(p.sketch.Quantile(p.quantile))
+}
+
+type distinct_t struct{ counter *hll.Counter }
+
+func (d *distinct_t) add(value any) { d.counter.Add(fmt.Sprint(value)) }
+func (d *distinct_t) result() any   { return d.counter.Count() }
+
+// whole numbers are shown without decimals
+func roundResult(value float64) any {
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return int64(value)
+	}
+
+	return math.Round(value*100) / 100
+}
+
+func (a agg_t) create() accumulator_t {
+	switch a.function {
+	case "count":
+		return &count_t{}
+	case "sum":
+		return &sum_t{}
+	case "avg":
+		return &avg_t{}
+	case "min":
+		return &extreme_t{}
+	case "max":
+		return &extreme_t{max: true}
+	case "count_distinct":
+		if a.exact {
+			return &distinct_t{counter: hll.Create(-1)}
+		}
+
+		return &distinct_t{counter: hll.Create(hll.DEFAULT_EXACT_LIMIT)}
+	}
+
+	return &percentile_t{sketch: sketch.Create(sketch.DEFAULT_ACCURACY), quantile: a.quantile}
+}
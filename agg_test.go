@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcos-venicius/quang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAggs(t *testing.T) {
+	configs := &Configs{fields: map[string]int{"latency": 10}}
+
+	cases := []struct {
+		list     string
+		expected []agg_t
+	}{
+		{"count()", []agg_t{{name: "count()", function: "count"}}},
+		{"sum(size), avg( latency )", []agg_t{{name: "sum(size)", function: "sum", field: "size"}, {name: "avg( latency )", function: "avg", field: "latency"}}},
+		{"min(size),max(size)", []agg_t{{name: "min(size)", function: "min", field: "size"}, {name: "max(size)", function: "max", field: "size"}}},
+		{"p0(size)", []agg_t{{name: "p0(size)", function: "p0", field: "size", quantile: 0}}},
+		{"p99.5(latency)", []agg_t{{name: "p99.5(latency)", function: "p99.5", field: "latency", quantile: 0.995}}},
+		{"p100(size)", []agg_t{{name: "p100(size)", function: "p100", field: "size", quantile: 1}}},
+		{"p50(param_page)", []agg_t{{name: "p50(param_page)", function: "p50", field: "param_page", quantile: 0.5}}},
+	}
+
+	for _, c := range cases {
+		aggs, err := parseAggs(c.list, configs, false)
+
+		assert.Nil(t, err, c.list)
+		assert.Equal(t, c.expected, aggs, c.list)
+	}
+
+	errors := map[string]string{
+		"":                 "missing aggregations in \"\"",
+		"sum":              "invalid aggregation \"sum\". expected something like sum(size)",
+		"count(size)":      "count does not take a field, use count()",
+		"median(size)":     "unknown aggregation \"median\"",
+		"percentile(size)": "unknown aggregation \"percentile\"",
+		"pmax(size)":       "unknown aggregation \"pmax\"",
+		"p(size)":          "unknown aggregation \"p\"",
+		"p101(size)":       "invalid percentile \"p101\". it should be between p0 and p100",
+		"avg()":            "avg needs a field, like avg(size)",
+		"max(duration)":    "unknown field \"duration\"",
+	}
+
+	for list, expected := range errors {
+		_, err := parseAggs(list, configs, false)
+
+		if assert.NotNil(t, err, list) {
+			assert.Equal(t, expected, err.Error(), list)
+		}
+	}
+}
+
+func TestNumberOf(t *testing.T) {
+	cases := []struct {
+		value  any
+		number float64
+		ok     bool
+	}{
+		{quang.IntegerType(3), 3, true},
+		{quang.FloatType(0.25), 0.25, true},
+		{7, 7, true},
+		{"1.5", 1.5, true},
+		{"GET", 0, false},
+		{nil, 0, false},
+	}
+
+	for _, c := range cases {
+		number, ok := numberOf(c.value)
+
+		assert.Equal(t, c.ok, ok, c.value)
+		assert.Equal(t, c.number, number, c.value)
+	}
+}
+
+// the result of the aggregation over the values
+func accumulated(function string, quantile float64, values ...any) any {
+	accumulator := agg_t{function: function, quantile: quantile}.create()
+
+	for _, value := range values {
+		accumulator.add(value)
+	}
+
+	return accumulator.result()
+}
+
+func TestAccumulators(t *testing.T) {
+	sizes := []any{quang.IntegerType(10), quang.IntegerType(30), "-", quang.FloatType(20.5)}
+
+	assert.Equal(t, 4, accumulated("count", 0, sizes...))
+	assert.Equal(t, 60.5, accumulated("sum", 0, sizes...))
+	assert.Equal(t, 20.17, accumulated("avg", 0, sizes...))
+	assert.Equal(t, int64(10), accumulated("min", 0, sizes...))
+	assert.Equal(t, int64(30), accumulated("max", 0, sizes...))
+
+	// the results of groups without numbers are missing, except the sum
+	assert.Equal(t, int64(0), accumulated("sum", 0, "-"))
+	assert.Nil(t, accumulated("avg", 0, "-"))
+	assert.Nil(t, accumulated("min", 0))
+	assert.Nil(t, accumulated("p50", 0.5))
+
+	latencies := make([]any, 0, 100)
+
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, quang.IntegerType(i))
+	}
+
+	assert.InDelta(t, 1, accumulated("p0", 0, latencies...), 0.05)
+	assert.InDelta(t, 50, accumulated("p50", 0.5, latencies...), 1)
+	assert.InDelta(t, 100, accumulated("p100", 1, latencies...), 2)
+}
+
+func TestRoundResult(t *testing.T) {
+	assert.Equal(t, int64(3), roundResult(3))
+	assert.Equal(t, 3.14, roundResult(3.14159))
+	assert.Equal(t, int64(-2), roundResult(-2))
+}
+
+func TestCountDistinct(t *testing.T) {
+	aggs, err := parseAggs("count_distinct(ip)", &Configs{}, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []agg_t{{name: "count_distinct(ip)", function: "count_distinct", field: "ip", exact: true}}, aggs)
+
+	ips := []any{"10.0.0.1", "10.0.0.2", "10.0.0.1", quang.IntegerType(3), "3"}
+
+	// the integer 3 and the string "3" are the same value
+	assert.EqualValues(t, 3, accumulated("count_distinct", 0, ips...))
+	assert.EqualValues(t, 0, accumulated("count_distinct", 0))
+
+	many := make([]any, 0, 20000)
+
+	for i := range 20000 {
+		many = append(many, quang.IntegerType(i))
+	}
+
+	// past the exact limit the count is estimated, unless it's exact
+	assert.InEpsilon(t, 20000, accumulated("count_distinct", 0, many...), 0.02)
+
+	exact := agg_t{function: "count_distinct", exact: true}.create()
+
+	for _, value := range many {
+		exact.add(value)
+	}
+
+	assert.EqualValues(t, 20000, exact.result())
+}
+
+func TestCountDistinctPerGroup(t *testing.T) {
+	aggs, _ := parseAggs("count_distinct(ip)", &Configs{}, false)
+	aggregator := createAggregator([]string{"status"}, aggs, 0, "csv")
+
+	for _, log := range []log_t{
+		{ip: "10.0.0.1", statusCode: 200},
+		{ip: "10.0.0.2", statusCode: 200},
+		{ip: "10.0.0.1", statusCode: 200},
+		{ip: "10.0.0.1", statusCode: 404},
+	} {
+		aggregator.add(log)
+	}
+
+	var builder strings.Builder
+
+	aggregator.write(&builder)
+
+	assert.Equal(t, "status,count,count_distinct(ip)\n200,3,2\n404,1,1\n", builder.String())
+}
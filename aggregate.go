@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type group_t struct {
+	// the values of the group by fields, in the same order
+	values       []any
+	count        int
+	accumulators []accumulator_t
+}
+
+type aggregator_t struct {
+	fields []string
+	aggs   []agg_t
+	top    int
+	output string
+
+	groups map[string]*group_t
+	total  int
+	mutex  sync.Mutex
+}
+
+var outputFormats = []string{"table", "json", "csv"}
+
+// "ip, status" -> ["ip", "status"]
+func parseFieldList(list string, configs *Configs) ([]string, error) {
+	fields := make([]string, 0)
+
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+
+		if len(field) == 0 {
+			continue
+		}
+
+		if !isKnownField(field, configs) {
+			return nil, fmt.Errorf("unknown field \"%s\"", field)
+		}
+
+		fields = append(fields, field)
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("missing fields in \"%s\"", list)
+	}
+
+	return fields, nil
+}
+
+func isKnownField(name string, configs *Configs) bool {
+	if _, ok := (log_t{}).values()[name]; ok {
+		return true
+	}
+
+	if _, ok := configs.fields[name]; ok {
+		return true
+	}
+
+	_, ok := (log_t{}).dynamicField(name)
+
+	return ok
+}
+
+// the value of any field, including param_<name> and segment_<ordinal>
+func (l log_t) field(name string, values map[string]any) any {
+	if value, ok := values[name]; ok {
+		return value
+	}
+
+	value, _ := l.dynamicField(name)
+
+	return value
+}
+
+func validateOutputFormat(output string) error {
+	for _, format := range outputFormats {
+		if format == output {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid output \"%s\". expected one of %s", output, strings.Join(outputFormats, ", "))
+}
+
+func createAggregator(fields []string, aggs []agg_t, top int, output string) *aggregator_t {
+	return &aggregator_t{
+		fields: fields,
+		aggs:   aggs,
+		top:    top,
+		output: output,
+		groups: make(map[string]*group_t),
+	}
+}
+
+func (a *aggregator_t) add(log log_t) {
+	values := log.values()
+	keyValues := make([]any, len(a.fields))
+	keys := make([]string, len(a.fields))
+
+	for i, field := range a.fields {
+		keyValues[i] = log.field(field, values)
+		keys[i] = fmt.Sprint(keyValues[i])
+	}
+
+	key := strings.Join(keys, "\x00")
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	group, ok := a.groups[key]
+
+	if !ok {
+		group = &group_t{values: keyValues, accumulators: make([]accumulator_t, len(a.aggs))}
+
+		for i, agg := range a.aggs {
+			group.accumulators[i] = agg.create()
+		}
+
+		a.groups[key] = group
+	}
+
+	group.count++
+	a.total++
+
+	group.accumulate(a.aggs, log, values)
+}
+
+func (g *group_t) accumulate(aggs []agg_t, log log_t, values map[string]any) {
+	for i, agg := range aggs {
+		if len(agg.field) == 0 {
+			g.accumulators[i].add(nil)
+		} else {
+			g.accumulators[i].add(log.field(agg.field, values))
+		}
+	}
+}
+
+// the names of the columns after the group by fields
+func (a *aggregator_t) columns() []string {
+	columns := []string{"count"}
+
+	for _, agg := range a.aggs {
+		columns = append(columns, agg.name)
+	}
+
+	return columns
+}
+
+func (g *group_t) results() []any {
+	results := []any{g.count}
+
+	for _, accumulator := range g.accumulators {
+		results = append(results, accumulator.result())
+	}
+
+	return results
+}
+
+// the groups with the biggest counts first, limited to the top n
+func (a *aggregator_t) sorted() []*group_t {
+	groups := make([]*group_t, 0, len(a.groups))
+
+	for _, group := range a.groups {
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+
+		return fmt.Sprint(groups[i].values...) < fmt.Sprint(groups[j].values...)
+	})
+
+	if a.top > 0 && len(groups) > a.top {
+		groups = groups[:a.top]
+	}
+
+	return groups
+}
+
+func (a *aggregator_t) write(w io.Writer) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	groups := a.sorted()
+	columns := a.columns()
+
+	switch a.output {
+	case "json":
+		rows := make([]map[string]any, 0, len(groups))
+
+		for _, group := range groups {
+			row := make(map[string]any)
+
+			for i, field := range a.fields {
+				row[field] = group.values[i]
+			}
+
+			for i, result := range group.results() {
+				row[columns[i]] = result
+			}
+
+			rows = append(rows, row)
+		}
+
+		encoder := json.NewEncoder(w)
+
+		return encoder.Encode(rows)
+	case "csv":
+		writer := csv.NewWriter(w)
+
+		writer.Write(append(append([]string{}, a.fields...), columns...))
+
+		for _, group := range groups {
+			row := make([]string, 0, len(a.fields)+len(columns))
+
+			for _, value := range append(group.values, group.results()...) {
+				row = append(row, displayResult(value))
+			}
+
+			writer.Write(row)
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, field := range a.fields {
+		fmt.Fprintf(writer, "%s\t", strings.ToUpper(field))
+	}
+
+	fmt.Fprintf(writer, "%s\t%%\n", strings.ToUpper(strings.Join(columns, "\t")))
+
+	for _, group := range groups {
+		for _, value := range append(group.values, group.results()...) {
+			fmt.Fprintf(writer, "%s\t", displayResult(value))
+		}
+
+		fmt.Fprintf(writer, "%.2f\n", float64(group.count)*100/float64(max(a.total, 1)))
+	}
+
+	return writer.Flush()
+}
+
+// missing results, like the avg of a group without numbers, are shown as "-"
+func displayResult(value any) string {
+	if value == nil {
+		return "-"
+	}
+
+	return fmt.Sprint(value)
+}
+
+// a report counts the matching logs, and is shown instead of them, like
+// -group-by or -threat-report
+type report_t interface {
+	add(log log_t)
+	write(w io.Writer) error
+}
+
+// writes the report every interval until stop is closed, and closes done
+// once it's not writing anymore
+func reportPeriodically(reporter report_t, output string, interval time.Duration, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if output == "table" {
+				fmt.Printf("-- %s\n", now.Format(time.DateTime))
+			}
+
+			reporter.write(os.Stdout)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marcos-venicius/quang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFieldList(t *testing.T) {
+	configs := &Configs{fields: map[string]int{"latency": 10}}
+
+	cases := map[string][]string{
+		"ip":                          {"ip"},
+		"ip, status":                  {"ip", "status"},
+		" route ,, latency ":          {"route", "latency"},
+		"segment_one,param_page_size": {"segment_one", "param_page_size"},
+	}
+
+	for list, expected := range cases {
+		fields, err := parseFieldList(list, configs)
+
+		assert.Nil(t, err, list)
+		assert.Equal(t, expected, fields, list)
+	}
+
+	for _, list := range []string{"", " , ", "ip, nope", "segment_eleven"} {
+		_, err := parseFieldList(list, configs)
+
+		assert.NotNil(t, err, list)
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, output := range outputFormats {
+		assert.Nil(t, validateOutputFormat(output))
+	}
+
+	assert.NotNil(t, validateOutputFormat("yaml"))
+}
+
+func aggregated(fields []string, top int, output string, logs ...log_t) string {
+	aggregator := createAggregator(fields, nil, top, output)
+
+	for _, log := range logs {
+		aggregator.add(log)
+	}
+
+	var builder strings.Builder
+
+	aggregator.write(&builder)
+
+	return builder.String()
+}
+
+func TestAggregator(t *testing.T) {
+	logs := []log_t{
+		{ip: "10.0.0.1", statusCode: 200},
+		{ip: "10.0.0.2", statusCode: 404},
+		{ip: "10.0.0.1", statusCode: 200},
+		{ip: "10.0.0.3", statusCode: 200},
+		{ip: "10.0.0.1", statusCode: 500},
+	}
+
+	cases := []struct {
+		fields   []string
+		top      int
+		output   string
+		expected string
+	}{
+		{[]string{"ip"}, 0, "csv", "ip,count\n10.0.0.1,3\n10.0.0.2,1\n10.0.0.3,1\n"},
+		{[]string{"ip"}, 1, "csv", "ip,count\n10.0.0.1,3\n"},
+		{[]string{"ip", "status"}, 2, "csv", "ip,status,count\n10.0.0.1,200,2\n10.0.0.1,500,1\n"},
+		{[]string{"status"}, 0, "json", `[{"count":3,"status":200},{"count":1,"status":404},{"count":1,"status":500}]` + "\n"},
+		{[]string{"status"}, 1, "table", "STATUS  COUNT  %\n200     3      60.00\n"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, aggregated(c.fields, c.top, c.output, logs...), c.fields)
+	}
+}
+
+func TestFieldOfLog(t *testing.T) {
+	log := parsedResource("/api/users/42?page=2")
+	log.custom = map[string]any{"latency": quang.FloatType(0.5)}
+
+	values := log.values()
+
+	assert.Equal(t, "/api/users/42", log.field("path", values))
+	assert.Equal(t, "42", log.field("segment_three", values))
+	assert.Equal(t, "2", log.field("param_page", values))
+	assert.Equal(t, "", log.field("param_missing", values))
+	assert.Equal(t, quang.FloatType(0.5), log.field("latency", values))
+}
+
+// a report that counts how many times it was written
+type counted_report_t struct {
+	writes atomic.Int32
+}
+
+func (r *counted_report_t) add(log log_t) {}
+
+func (r *counted_report_t) write(w io.Writer) error {
+	r.writes.Add(1)
+
+	return nil
+}
+
+func TestReportPeriodically(t *testing.T) {
+	report := &counted_report_t{}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go reportPeriodically(report, "csv", time.Millisecond, stop, done)
+
+	assert.Eventually(t, func() bool { return report.writes.Load() > 1 }, time.Second, time.Millisecond)
+
+	close(stop)
+	<-done
+
+	// nothing is written once done is closed
+	writes := report.writes.Load()
+
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Equal(t, writes, report.writes.Load())
+}
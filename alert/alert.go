@@ -0,0 +1,170 @@
+package alert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many adds happen between the removals of the idle keys
+const PRUNE_INTERVAL = 10000
+
+// Rule fires when more than Threshold logs match Query within Window,
+// counting each value of the Per field apart when it is set
+type Rule struct {
+	Name      string
+	Threshold int
+	Window    time.Duration
+	Per       string
+	// the minimum time between two alerts of the same rule and key.
+	// the window by default
+	Cooldown time.Duration
+	Query    string
+	// a shell command executed on every alert
+	Exec string
+}
+
+var ruleRegex = regexp.MustCompile(`^([a-zA-Z0-9_-]+)\s+(\d+)\s+in\s+(\S+)((?:\s+(?:per|cooldown)\s+\S+)*)\s+when\s+(.+)$`)
+
+// "errors 50 in 1m per ip cooldown 5m when status gte 500"
+func ParseRule(text string) (Rule, error) {
+	matches := ruleRegex.FindStringSubmatch(strings.TrimSpace(text))
+
+	if matches == nil {
+		return Rule{}, fmt.Errorf("invalid alert \"%s\". expected something like \"errors 50 in 1m [per ip] [cooldown 5m] when status gte 500\"", text)
+	}
+
+	threshold, err := strconv.Atoi(matches[2])
+
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid threshold \"%s\"", matches[2])
+	}
+
+	window, err := time.ParseDuration(matches[3])
+
+	if err != nil || window < time.Second {
+		return Rule{}, fmt.Errorf("invalid window \"%s\". it should be a duration of at least 1s, like 30s or 5m", matches[3])
+	}
+
+	rule := Rule{
+		Name:      matches[1],
+		Threshold: threshold,
+		Window:    window,
+		Cooldown:  window,
+		Query:     strings.TrimSpace(matches[5]),
+	}
+
+	options := strings.Fields(matches[4])
+
+	for i := 0; i < len(options); i += 2 {
+		switch options[i] {
+		case "per":
+			rule.Per = options[i+1]
+		case "cooldown":
+			cooldown, err := time.ParseDuration(options[i+1])
+
+			if err != nil || cooldown < 0 {
+				return Rule{}, fmt.Errorf("invalid cooldown \"%s\"", options[i+1])
+			}
+
+			rule.Cooldown = cooldown
+		}
+	}
+
+	return rule, nil
+}
+
+type window_t struct {
+	// the times of the last matches, at most threshold + 1 of them
+	times []time.Time
+	fired time.Time
+}
+
+// Detector keeps the sliding windows of a rule
+type Detector struct {
+	Rule Rule
+
+	windows map[string]*window_t
+	adds    int
+	mutex   sync.Mutex
+}
+
+func CreateDetector(rule Rule) *Detector {
+	return &Detector{
+		Rule:    rule,
+		windows: make(map[string]*window_t),
+	}
+}
+
+// Add counts a match of key at t, which is usually the time of the log so
+// old logs are judged by their own time. it returns how many matches the
+// window has and if the alert should fire
+func (d *Detector) Add(key string, t time.Time) (int, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.adds++
+
+	if d.adds%PRUNE_INTERVAL == 0 {
+		d.prune(t)
+	}
+
+	w, ok := d.windows[key]
+
+	if !ok {
+		w = &window_t{times: make([]time.Time, 0, min(d.Rule.Threshold+1, 64))}
+		d.windows[key] = w
+	}
+
+	w.times = append(w.times, t)
+
+	start := t.Add(-d.Rule.Window)
+	expired := 0
+
+	for expired < len(w.times) && !w.times[expired].After(start) {
+		expired++
+	}
+
+	// only the last threshold + 1 matches are needed to know if it's over the threshold
+	expired = max(expired, len(w.times)-(d.Rule.Threshold+1))
+
+	if expired > 0 {
+		w.times = append(w.times[:0], w.times[expired:]...)
+	}
+
+	count := len(w.times)
+
+	if count <= d.Rule.Threshold {
+		return count, false
+	}
+
+	if !w.fired.IsZero() && t.Sub(w.fired) < d.Rule.Cooldown {
+		return count, false
+	}
+
+	w.fired = t
+
+	return count, true
+}
+
+// removes the keys without matches in the window, nor cooldown
+func (d *Detector) prune(now time.Time) {
+	for key, w := range d.windows {
+		last := w.times[len(w.times)-1]
+
+		if now.Sub(last) > d.Rule.Window && now.Sub(w.fired) > d.Rule.Cooldown {
+			delete(d.windows, key)
+		}
+	}
+}
+
+// how many keys are being watched
+func (d *Detector) Len() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return len(d.windows)
+}
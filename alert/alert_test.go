@@ -0,0 +1,108 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("errors 50 in 1m when status gte 500")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Rule{Name: "errors", Threshold: 50, Window: time.Minute, Cooldown: time.Minute, Query: "status gte 500"}, rule)
+
+	rule, err = ParseRule("login_flood 100 in 30s per ip cooldown 5m when method eq :post and path eq '/login'")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ip", rule.Per)
+	assert.Equal(t, 5*time.Minute, rule.Cooldown)
+	assert.Equal(t, 30*time.Second, rule.Window)
+	assert.Equal(t, "method eq :post and path eq '/login'", rule.Query)
+}
+
+func TestParseInvalidRule(t *testing.T) {
+	for _, text := range []string{
+		"errors 50 when status gte 500",
+		"errors 50 in 1m",
+		"errors 50 in 1x when status gte 500",
+		"errors 50 in 100ms when status gte 500",
+		"errors 50 in 1m cooldown soon when status gte 500",
+	} {
+		_, err := ParseRule(text)
+
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestDetectorFiresOverThreshold(t *testing.T) {
+	d := CreateDetector(Rule{Threshold: 3, Window: 10 * time.Second, Cooldown: 10 * time.Second})
+	start := time.Date(2025, 3, 28, 14, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		_, fire := d.Add("", start.Add(time.Duration(i)*time.Second))
+
+		assert.False(t, fire)
+	}
+
+	count, fire := d.Add("", start.Add(3*time.Second))
+
+	assert.True(t, fire)
+	assert.Equal(t, 4, count)
+
+	// still over the threshold, but cooling down
+	_, fire = d.Add("", start.Add(4*time.Second))
+
+	assert.False(t, fire)
+
+	// the old matches left the window
+	_, fire = d.Add("", start.Add(30*time.Second))
+
+	assert.False(t, fire)
+}
+
+func TestDetectorCooldown(t *testing.T) {
+	d := CreateDetector(Rule{Threshold: 1, Window: 10 * time.Second, Cooldown: 5 * time.Second})
+	start := time.Date(2025, 3, 28, 14, 0, 0, 0, time.UTC)
+	fired := 0
+
+	for i := 0; i < 20; i++ {
+		if _, fire := d.Add("", start.Add(time.Duration(i)*time.Second)); fire {
+			fired++
+		}
+	}
+
+	// at 1s, 6s, 11s and 16s
+	assert.Equal(t, 4, fired)
+}
+
+func TestDetectorPerKey(t *testing.T) {
+	d := CreateDetector(Rule{Threshold: 2, Window: time.Minute, Cooldown: time.Minute})
+	start := time.Date(2025, 3, 28, 14, 0, 0, 0, time.UTC)
+
+	d.Add("10.0.0.1", start)
+	d.Add("10.0.0.2", start)
+	d.Add("10.0.0.1", start)
+	_, fire := d.Add("10.0.0.2", start)
+
+	assert.False(t, fire)
+
+	_, fire = d.Add("10.0.0.1", start)
+
+	assert.True(t, fire)
+	assert.Equal(t, 2, d.Len())
+}
+
+func TestDetectorPrune(t *testing.T) {
+	d := CreateDetector(Rule{Threshold: 10, Window: time.Second})
+	start := time.Date(2025, 3, 28, 14, 0, 0, 0, time.UTC)
+
+	for i := 0; i < PRUNE_INTERVAL-1; i++ {
+		d.Add(string(rune(i)), start)
+	}
+
+	d.Add("last", start.Add(time.Hour))
+
+	assert.Equal(t, 1, d.Len())
+}
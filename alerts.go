@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcos-venicius/lfi/alert"
+	"github.com/marcos-venicius/lfi/webhook"
+	"github.com/marcos-venicius/quang"
+)
+
+type alert_rule_t struct {
+	detector         *alert.Detector
+	q                *quang.Quang
+	dynamicVariables []string
+}
+
+// alerter_t checks every log against the alert rules of the config
+type alerter_t struct {
+	rules  []alert_rule_t
+	output io.Writer
+	// where the alerts are also sent, when set
+	webhook *webhook.Sink
+	// the commands of the alerts still running
+	commands sync.WaitGroup
+	mutex    sync.Mutex
+}
+
+func createAlerter(rules []alert.Rule, alertFile string) (*alerter_t, error) {
+	alerter := &alerter_t{output: os.Stderr}
+
+	if len(alertFile) > 0 {
+		file, err := os.OpenFile(alertFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+
+		if err != nil {
+			return nil, err
+		}
+
+		alerter.output = file
+	}
+
+	for _, rule := range rules {
+		q, err := compileQuery(rule.Query)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid query of alert \"%s\": %s", rule.Name, err.Error())
+		}
+
+		alerter.rules = append(alerter.rules, alert_rule_t{
+			detector:         alert.CreateDetector(rule),
+			q:                q,
+			dynamicVariables: dynamicQueryVariables(rule.Query),
+		})
+	}
+
+	return alerter, nil
+}
+
+func (a *alerter_t) add(log log_t) {
+	t := log.judgedTime()
+
+	var values map[string]any
+
+	for _, rule := range a.rules {
+		log.bindQueryVariables(rule.q, rule.dynamicVariables)
+
+		matches, err := rule.q.Eval()
+
+		if err != nil || !matches {
+			continue
+		}
+
+		key := ""
+
+		if per := rule.detector.Rule.Per; len(per) > 0 {
+			if values == nil {
+				values = log.values()
+			}
+
+			key = fmt.Sprint(log.field(per, values))
+		}
+
+		if _, fire := rule.detector.Add(key, t); fire {
+			a.fire(rule.detector.Rule, key, t)
+		}
+	}
+}
+
+// 1m0s -> 1m
+func displayDuration(d time.Duration) string {
+	text := d.String()
+
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+
+	return text
+}
+
+// more than 50 logs matching "status gte 500" within 1m
+func alertMessage(rule alert.Rule, key string) string {
+	message := fmt.Sprintf("more than %d logs matching \"%s\" within %s", rule.Threshold, rule.Query, displayDuration(rule.Window))
+
+	if len(rule.Per) > 0 {
+		message += fmt.Sprintf(" for %s %s", rule.Per, key)
+	}
+
+	return message
+}
+
+func (a *alerter_t) fire(rule alert.Rule, key string, t time.Time) {
+	message := alertMessage(rule, key)
+
+	line := fmt.Sprintf("[%s] alert %s: %s", t.Format(time.DateTime), rule.Name, message)
+
+	a.mutex.Lock()
+	fmt.Fprintln(a.output, line)
+	a.mutex.Unlock()
+
+	if a.webhook != nil {
+		a.webhook.Send(alertEvent(rule, key, t, line))
+	}
+
+	if len(rule.Exec) == 0 {
+		return
+	}
+
+	command := exec.Command("sh", "-c", rule.Exec)
+
+	command.Env = append(os.Environ(),
+		"LFI_ALERT="+rule.Name,
+		"LFI_ALERT_KEY="+key,
+		"LFI_ALERT_TIME="+t.Format(time.RFC3339),
+		"LFI_ALERT_MESSAGE="+message,
+	)
+	command.Stdout = os.Stderr
+	command.Stderr = os.Stderr
+
+	if err := command.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: alert %s: %s\n", rule.Name, err.Error())
+		return
+	}
+
+	a.commands.Add(1)
+
+	go func() {
+		defer a.commands.Done()
+
+		if err := command.Wait(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: alert %s: %s\n", rule.Name, err.Error())
+		}
+	}()
+}
+
+// waits the commands of the alerts, so they are not killed when lfi exits
+func (a *alerter_t) wait() {
+	a.commands.Wait()
+
+	if closer, ok := a.output.(io.Closer); ok && a.output != os.Stderr {
+		closer.Close()
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/marcos-venicius/lfi/baseline"
+)
+
+func runBaseline(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "learn":
+			return runBaselineLearn(args[1:])
+		case "check":
+			return runBaselineCheck(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage of lfi baseline:\n  lfi baseline learn [flags] [files]\n    \tlearn what the traffic usually looks like, per hour of the day, from old logs\n  lfi baseline check [flags] baseline [file]\n    \tflag the buckets of new logs too far from the baseline\n")
+
+	return 1
+}
+
+func runBaselineLearn(args []string) int {
+	flags := flag.NewFlagSet("baseline learn", flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of lfi baseline learn: lfi baseline learn [flags] [files]\n\nlearns the requests of each route, the statuses and the sizes, per hour of the day, from the logs of the files or of stdin\n\n")
+		flags.PrintDefaults()
+	}
+
+	common := addLogFlags(flags)
+	output := flags.String("o", "baseline.json", "the file where the baseline is written")
+	bucket := flags.Duration("bucket", baseline.DEFAULT_BUCKET, "the period the requests are counted in, like 1m or 5m")
+
+	flags.Parse(args)
+
+	if *bucket < time.Second || time.Hour%*bucket != 0 {
+		fmt.Fprintln(os.Stderr, "error: -bucket should be at least 1s and divide an hour, like 1m, 5m or 15m")
+		return 1
+	}
+
+	configs, q, dynamicVariables, err := common.load()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	learner := baseline.CreateLearner(*bucket)
+	learn := func(log log_t, t time.Time) {
+		if t.IsZero() {
+			return
+		}
+
+		learner.Add(log.route, statusClass(log.statusCode), float64(log.size), t)
+	}
+
+	if flags.NArg() == 0 {
+		readMatchingLogs(os.Stdin, *common.breakParamsOut, configs, q, dynamicVariables, learn)
+	}
+
+	for _, path := range flags.Args() {
+		file, err := os.Open(path)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			return 1
+		}
+
+		readMatchingLogs(file, *common.breakParamsOut, configs, q, dynamicVariables, learn)
+
+		file.Close()
+	}
+
+	profile, err := learner.Profile()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	if err := profile.Save(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// [2024-10-10 13:05:00] anomaly route /login: 420 requests, expected 40 (10.5x, score 63.3)
+func anomalyMessage(a baseline.Anomaly) string {
+	subject := a.Kind
+
+	if len(a.Key) > 0 {
+		subject += " " + a.Key
+	}
+
+	value := fmt.Sprintf("%.0f requests, expected %.1f", a.Value, a.Expected)
+
+	if a.Kind == "size" {
+		value = fmt.Sprintf("%s on average, expected %s", displayBytes(int64(a.Value)), displayBytes(int64(a.Expected)))
+	}
+
+	return fmt.Sprintf("[%s] anomaly %s: %s (%.1fx, score %.1f)", a.Start.Format(time.DateTime), subject, value, a.Ratio(), a.Score)
+}
+
+func runBaselineCheck(args []string) int {
+	flags := flag.NewFlagSet("baseline check", flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of lfi baseline check: lfi baseline check [flags] baseline [file]\n\nflags the buckets of the logs of stdin, or of the lines appended to file, too far from the baseline\n\n")
+		flags.PrintDefaults()
+	}
+
+	common := addLogFlags(flags)
+	threshold := flags.Float64("threshold", baseline.DEFAULT_THRESHOLD, "how many standard deviations away from the baseline is an anomaly")
+	minCount := flags.Int("min", baseline.DEFAULT_MIN_COUNT, "counts smaller than it, both in the baseline and in the logs, are never anomalies")
+
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return 1
+	}
+
+	if *threshold <= 0 || *minCount < 1 {
+		fmt.Fprintln(os.Stderr, "error: -threshold should be positive and -min at least 1")
+		return 1
+	}
+
+	profile, err := baseline.Load(flags.Arg(0))
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	configs, q, dynamicVariables, err := common.load()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	var input io.Reader = os.Stdin
+
+	if flags.NArg() == 2 {
+		follower, err := followFile(flags.Arg(1))
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			return 1
+		}
+
+		defer follower.Close()
+
+		input = follower
+	}
+
+	checker := baseline.CreateChecker(profile, baseline.Options{Threshold: *threshold, MinCount: *minCount})
+
+	var mutex sync.Mutex
+
+	printAnomalies := func(anomalies []baseline.Anomaly) {
+		for _, anomaly := range anomalies {
+			fmt.Println(anomalyMessage(anomaly))
+		}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		readMatchingLogs(input, *common.breakParamsOut, configs, q, dynamicVariables, func(log log_t, t time.Time) {
+			if t.IsZero() {
+				return
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			printAnomalies(checker.Add(log.route, statusClass(log.statusCode), float64(log.size), t))
+		})
+	}()
+
+	// a bucket is checked once a log of the next one arrives, or once it
+	// ended by the clock, so an outage is flagged while following
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		case now := <-ticker.C:
+			mutex.Lock()
+			printAnomalies(checker.Tick(now))
+			mutex.Unlock()
+		}
+	}
+
+	printAnomalies(checker.Flush())
+
+	return 0
+}
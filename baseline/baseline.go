@@ -0,0 +1,504 @@
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	DEFAULT_BUCKET = time.Minute
+	// how many standard deviations away from the mean is an anomaly
+	DEFAULT_THRESHOLD = 4
+	// buckets with fewer requests than it, in the baseline and in the logs, are not judged
+	DEFAULT_MIN_COUNT = 10
+	// the empty buckets between two logs are only counted when the gap is
+	// smaller than it, so a file with a hole of days does not look quiet
+	MAX_GAP = 24 * time.Hour
+	// logs are written a little after their requests, so the clock only
+	// closes a bucket this long after it ends
+	GRACE = 5 * time.Second
+)
+
+// Series is a count observed in every bucket of an hour of the day
+type Series struct {
+	Sum        float64 `json:"sum"`
+	SumSquares float64 `json:"sum_squares"`
+}
+
+func (s *Series) add(value float64) {
+	s.Sum += value
+	s.SumSquares += value * value
+}
+
+// the mean and the standard deviation of the series over n buckets. buckets
+// where it was not seen count as zero
+func (s *Series) stats(n int) (float64, float64) {
+	if s == nil || n == 0 {
+		return 0, 0
+	}
+
+	mean := s.Sum / float64(n)
+	variance := s.SumSquares/float64(n) - mean*mean
+
+	return mean, math.Sqrt(max(variance, 0))
+}
+
+// Hour is what an hour of the day usually looks like
+type Hour struct {
+	Buckets  int                `json:"buckets"`
+	Total    Series             `json:"total"`
+	Routes   map[string]*Series `json:"routes"`
+	Statuses map[string]*Series `json:"statuses"`
+	// the average size of the responses of each bucket with requests
+	SizeBuckets int    `json:"size_buckets"`
+	Size        Series `json:"size"`
+}
+
+func createHour() *Hour {
+	return &Hour{
+		Routes:   make(map[string]*Series),
+		Statuses: make(map[string]*Series),
+	}
+}
+
+func (h *Hour) add(b *Bucket) {
+	h.Buckets++
+	h.Total.add(float64(b.Total))
+
+	for route, count := range b.Routes {
+		if _, ok := h.Routes[route]; !ok {
+			h.Routes[route] = &Series{}
+		}
+
+		h.Routes[route].add(float64(count))
+	}
+
+	for status, count := range b.Statuses {
+		if _, ok := h.Statuses[status]; !ok {
+			h.Statuses[status] = &Series{}
+		}
+
+		h.Statuses[status].add(float64(count))
+	}
+
+	if b.Total > 0 {
+		h.SizeBuckets++
+		h.Size.add(b.Size / float64(b.Total))
+	}
+}
+
+func (h *Hour) merge(other *Hour) {
+	h.Buckets += other.Buckets
+	h.Total.Sum += other.Total.Sum
+	h.Total.SumSquares += other.Total.SumSquares
+
+	for route, series := range other.Routes {
+		if _, ok := h.Routes[route]; !ok {
+			h.Routes[route] = &Series{}
+		}
+
+		h.Routes[route].Sum += series.Sum
+		h.Routes[route].SumSquares += series.SumSquares
+	}
+
+	for status, series := range other.Statuses {
+		if _, ok := h.Statuses[status]; !ok {
+			h.Statuses[status] = &Series{}
+		}
+
+		h.Statuses[status].Sum += series.Sum
+		h.Statuses[status].SumSquares += series.SumSquares
+	}
+
+	h.SizeBuckets += other.SizeBuckets
+	h.Size.Sum += other.Size.Sum
+	h.Size.SumSquares += other.Size.SumSquares
+}
+
+// Profile is the learned baseline, an Hour for each hour of the day
+type Profile struct {
+	Bucket string    `json:"bucket"`
+	Hours  [24]*Hour `json:"hours"`
+
+	bucket time.Duration
+	// every hour together, for the hours without logs
+	all *Hour
+}
+
+func createProfile(bucket time.Duration) *Profile {
+	p := &Profile{Bucket: bucket.String(), bucket: bucket}
+
+	for i := range p.Hours {
+		p.Hours[i] = createHour()
+	}
+
+	return p
+}
+
+func (p *Profile) index() error {
+	bucket, err := time.ParseDuration(p.Bucket)
+
+	if err != nil || bucket <= 0 {
+		return fmt.Errorf("invalid bucket \"%s\"", p.Bucket)
+	}
+
+	p.bucket = bucket
+	p.all = createHour()
+
+	for i, hour := range p.Hours {
+		if hour == nil {
+			hour = createHour()
+			p.Hours[i] = hour
+		}
+
+		p.all.merge(hour)
+	}
+
+	if p.all.Buckets == 0 {
+		return fmt.Errorf("the baseline has no logs")
+	}
+
+	return nil
+}
+
+// the hour of t, or every hour together when it had no logs
+func (p *Profile) hour(t time.Time) *Hour {
+	if hour := p.Hours[t.Hour()]; hour.Buckets > 0 {
+		return hour
+	}
+
+	return p.all
+}
+
+func (p *Profile) Save(path string) error {
+	data, err := json.Marshal(p)
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+func Load(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Profile{}
+
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %s", path, err.Error())
+	}
+
+	if err := p.index(); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %s", path, err.Error())
+	}
+
+	return p, nil
+}
+
+// Bucket counts the requests of a period, like a minute
+type Bucket struct {
+	Start    time.Time
+	Total    int
+	Routes   map[string]int
+	Statuses map[string]int
+	// the sum of the sizes of the responses
+	Size float64
+}
+
+func createBucket(start time.Time) *Bucket {
+	return &Bucket{
+		Start:    start,
+		Routes:   make(map[string]int),
+		Statuses: make(map[string]int),
+	}
+}
+
+func (b *Bucket) add(route string, status string, size float64) {
+	b.Total++
+	b.Routes[route]++
+	b.Statuses[status]++
+	b.Size += size
+}
+
+// splitter_t groups the requests in buckets by their time
+type splitter_t struct {
+	size    time.Duration
+	current *Bucket
+}
+
+// adds a request, returning the buckets closed by it, with the empty ones
+// between them
+func (s *splitter_t) add(route string, status string, size float64, t time.Time) []*Bucket {
+	closed := s.advance(t)
+
+	if s.current == nil {
+		s.current = createBucket(t.Truncate(s.size))
+	}
+
+	// late requests are counted in the current bucket
+	s.current.add(route, status, size)
+
+	return closed
+}
+
+// closes the buckets before the one of t, with the empty ones between them,
+// and starts an empty one at t
+func (s *splitter_t) advance(t time.Time) []*Bucket {
+	start := t.Truncate(s.size)
+	closed := make([]*Bucket, 0)
+
+	if s.current == nil || !start.After(s.current.Start) {
+		return closed
+	}
+
+	closed = append(closed, s.current)
+
+	if start.Sub(s.current.Start) < MAX_GAP {
+		for empty := s.current.Start.Add(s.size); empty.Before(start); empty = empty.Add(s.size) {
+			closed = append(closed, createBucket(empty))
+		}
+	}
+
+	s.current = createBucket(start)
+
+	return closed
+}
+
+// returns the current bucket, unless nothing was counted in it yet
+func (s *splitter_t) flush() []*Bucket {
+	current := s.current
+
+	s.current = nil
+
+	if current == nil || current.Total == 0 {
+		return nil
+	}
+
+	return []*Bucket{current}
+}
+
+// Learner builds a profile from old logs
+type Learner struct {
+	size time.Duration
+	// the buckets by their start, so the files can be read in any order
+	buckets map[int64]*Bucket
+}
+
+func CreateLearner(bucket time.Duration) *Learner {
+	return &Learner{
+		size:    bucket,
+		buckets: make(map[int64]*Bucket),
+	}
+}
+
+// Add counts a request at t, the time of the log
+func (l *Learner) Add(route string, status string, size float64, t time.Time) {
+	start := t.Truncate(l.size)
+	b, ok := l.buckets[start.Unix()]
+
+	if !ok {
+		b = createBucket(start)
+		l.buckets[start.Unix()] = b
+	}
+
+	b.add(route, status, size)
+}
+
+// Profile returns what was learned, with the empty buckets between the
+// logs
+func (l *Learner) Profile() (*Profile, error) {
+	profile := createProfile(l.size)
+	starts := make([]int64, 0, len(l.buckets))
+
+	for start := range l.buckets {
+		starts = append(starts, start)
+	}
+
+	slices.Sort(starts)
+
+	var previous *Bucket
+
+	for _, start := range starts {
+		b := l.buckets[start]
+
+		if previous != nil && b.Start.Sub(previous.Start) < MAX_GAP {
+			for empty := previous.Start.Add(l.size); empty.Before(b.Start); empty = empty.Add(l.size) {
+				profile.Hours[empty.Hour()].add(createBucket(empty))
+			}
+		}
+
+		profile.Hours[b.Start.Hour()].add(b)
+		previous = b
+	}
+
+	if err := profile.index(); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+type Options struct {
+	Threshold float64
+	MinCount  int
+}
+
+// Anomaly is a count of a bucket too far from the baseline
+type Anomaly struct {
+	Start time.Time
+	// "total", "route", "status" or "size"
+	Kind string
+	// the route or the status class
+	Key      string
+	Value    float64
+	Expected float64
+	// how many standard deviations the value is from the expected one
+	Score float64
+}
+
+// how many times the expected value the value is
+func (a Anomaly) Ratio() float64 {
+	return a.Value / max(a.Expected, 1)
+}
+
+// Checker compares new logs with a profile
+type Checker struct {
+	profile  *Profile
+	options  Options
+	splitter splitter_t
+	// the time of the newest log and when it was read, the clock of Tick
+	newest time.Time
+	read   time.Time
+}
+
+func CreateChecker(profile *Profile, options Options) *Checker {
+	if options.Threshold <= 0 {
+		options.Threshold = DEFAULT_THRESHOLD
+	}
+
+	if options.MinCount < 1 {
+		options.MinCount = DEFAULT_MIN_COUNT
+	}
+
+	return &Checker{
+		profile:  profile,
+		options:  options,
+		splitter: splitter_t{size: profile.bucket},
+	}
+}
+
+// Add counts a request at t, the time of the log, and returns the
+// anomalies of the buckets closed by it
+func (c *Checker) Add(route string, status string, size float64, t time.Time) []Anomaly {
+	if t.After(c.newest) {
+		c.newest = t
+		c.read = time.Now()
+	}
+
+	return c.check(c.splitter.add(route, status, size, t))
+}
+
+// Tick closes the buckets that ended by now, in the time of the logs, so
+// the quiet ones are checked without waiting for the next log. the time of
+// the logs is the time of the newest one plus how long ago it was read
+func (c *Checker) Tick(now time.Time) []Anomaly {
+	if c.newest.IsZero() {
+		return nil
+	}
+
+	return c.check(c.splitter.advance(c.newest.Add(now.Sub(c.read) - GRACE)))
+}
+
+// Flush returns the anomalies of the last bucket
+func (c *Checker) Flush() []Anomaly {
+	return c.check(c.splitter.flush())
+}
+
+func (c *Checker) check(buckets []*Bucket) []Anomaly {
+	anomalies := make([]Anomaly, 0)
+
+	for _, b := range buckets {
+		anomalies = append(anomalies, c.checkBucket(b)...)
+	}
+
+	return anomalies
+}
+
+// counts are compared with a poisson floor on the deviation, so steady
+// series with almost no deviation don't flag every small change
+func (c *Checker) compare(start time.Time, kind string, key string, value int, series *Series, n int) (Anomaly, bool) {
+	mean, deviation := series.stats(n)
+
+	if value < c.options.MinCount && mean < float64(c.options.MinCount) {
+		return Anomaly{}, false
+	}
+
+	deviation = max(deviation, math.Sqrt(mean), 1)
+	score := (float64(value) - mean) / deviation
+
+	if math.Abs(score) < c.options.Threshold {
+		return Anomaly{}, false
+	}
+
+	return Anomaly{Start: start, Kind: kind, Key: key, Value: float64(value), Expected: mean, Score: score}, true
+}
+
+func (c *Checker) checkBucket(b *Bucket) []Anomaly {
+	hour := c.profile.hour(b.Start)
+	anomalies := make([]Anomaly, 0)
+
+	if anomaly, ok := c.compare(b.Start, "total", "", b.Total, &hour.Total, hour.Buckets); ok {
+		anomalies = append(anomalies, anomaly)
+	}
+
+	for _, kind := range []string{"route", "status"} {
+		seen, expected := b.Routes, hour.Routes
+
+		if kind == "status" {
+			seen, expected = b.Statuses, hour.Statuses
+		}
+
+		keys := make([]string, 0, len(seen)+len(expected))
+
+		for key := range seen {
+			keys = append(keys, key)
+		}
+
+		for key := range expected {
+			if _, ok := seen[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if anomaly, ok := c.compare(b.Start, kind, key, seen[key], expected[key], hour.Buckets); ok {
+				anomalies = append(anomalies, anomaly)
+			}
+		}
+	}
+
+	// sizes are steadier than counts, so their floor is a tenth of the mean
+	if b.Total >= c.options.MinCount && hour.SizeBuckets > 0 {
+		mean, deviation := hour.Size.stats(hour.SizeBuckets)
+		value := b.Size / float64(b.Total)
+		score := (value - mean) / max(deviation, mean/10, 1)
+
+		if math.Abs(score) >= c.options.Threshold {
+			anomalies = append(anomalies, Anomaly{Start: b.Start, Kind: "size", Value: value, Expected: mean, Score: score})
+		}
+	}
+
+	return anomalies
+}
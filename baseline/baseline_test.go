@@ -0,0 +1,196 @@
+package baseline
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2024, 10, 10, 13, 0, 0, 0, time.UTC)
+
+// ten requests to /users and one to /login a minute, for an hour
+func learn(t *testing.T) *Profile {
+	l := CreateLearner(time.Minute)
+
+	for minute := range 60 {
+		at := start.Add(time.Duration(minute) * time.Minute)
+
+		for i := range 10 + minute%3 {
+			l.Add("/users", "2xx", 1000, at.Add(time.Duration(i)*time.Second))
+		}
+
+		l.Add("/login", "2xx", 100, at.Add(30*time.Second))
+	}
+
+	p, err := l.Profile()
+
+	assert.Nil(t, err)
+
+	return p
+}
+
+func TestLearn(t *testing.T) {
+	p := learn(t)
+	hour := p.Hours[13]
+
+	assert.Equal(t, 60, hour.Buckets)
+	assert.Equal(t, 0, p.Hours[12].Buckets)
+
+	mean, deviation := hour.Routes["/users"].stats(hour.Buckets)
+
+	assert.Equal(t, 11.0, mean)
+	assert.InDelta(t, 0.816, deviation, 0.001)
+
+	mean, _ = hour.Routes["/login"].stats(hour.Buckets)
+
+	assert.Equal(t, 1.0, mean)
+}
+
+func TestEmptyBucketsAreCounted(t *testing.T) {
+	l := CreateLearner(time.Minute)
+
+	l.Add("/users", "2xx", 10, start)
+	l.Add("/users", "2xx", 10, start.Add(4*time.Minute))
+
+	p, err := l.Profile()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, p.Hours[13].Buckets)
+	assert.Equal(t, 2, p.Hours[13].SizeBuckets)
+}
+
+func TestLearnOutOfOrder(t *testing.T) {
+	l := CreateLearner(time.Minute)
+
+	// the newer file is read first
+	l.Add("/users", "2xx", 10, start.Add(30*time.Minute))
+	l.Add("/users", "2xx", 10, start)
+	l.Add("/users", "2xx", 10, start.Add(time.Minute))
+
+	p, err := l.Profile()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 31, p.Hours[13].Buckets)
+	assert.Equal(t, 3, p.Hours[13].SizeBuckets)
+	assert.Equal(t, 3.0, p.Hours[13].Total.SumSquares)
+}
+
+func TestEmptyProfile(t *testing.T) {
+	_, err := CreateLearner(time.Minute).Profile()
+
+	assert.NotNil(t, err)
+}
+
+func check(c *Checker, at time.Time, route string, status string, size float64, n int) {
+	for i := range n {
+		c.Add(route, status, size, at.Add(time.Duration(i)*time.Millisecond))
+	}
+}
+
+func TestNormalTraffic(t *testing.T) {
+	c := CreateChecker(learn(t), Options{})
+	at := start.Add(24 * time.Hour)
+
+	check(c, at, "/users", "2xx", 1000, 12)
+	check(c, at, "/login", "2xx", 100, 1)
+
+	assert.Empty(t, c.Flush())
+}
+
+func TestRouteSpike(t *testing.T) {
+	c := CreateChecker(learn(t), Options{})
+	at := start.Add(24 * time.Hour)
+
+	check(c, at, "/users", "2xx", 1000, 110)
+
+	anomalies := c.Add("/users", "2xx", 1000, at.Add(time.Minute))
+
+	assert.Len(t, anomalies, 3)
+	assert.Equal(t, "total", anomalies[0].Kind)
+	assert.Equal(t, Anomaly{Start: at, Kind: "route", Key: "/users", Value: 110, Expected: 11, Score: anomalies[1].Score}, anomalies[1])
+	assert.Equal(t, 10.0, anomalies[1].Ratio())
+	assert.Equal(t, "status", anomalies[2].Kind)
+	assert.Equal(t, "2xx", anomalies[2].Key)
+}
+
+func TestErrorsAndNewRoutes(t *testing.T) {
+	c := CreateChecker(learn(t), Options{})
+	at := start.Add(24 * time.Hour)
+
+	check(c, at, "/users", "2xx", 1000, 6)
+	check(c, at, "/users", "5xx", 1000, 5)
+	check(c, at, "/admin", "5xx", 1000, 10)
+
+	anomalies := c.Flush()
+
+	assert.Len(t, anomalies, 2)
+	assert.Equal(t, Anomaly{Start: at, Kind: "route", Key: "/admin", Value: 10, Expected: 0, Score: 10}, anomalies[0])
+	assert.Equal(t, "5xx", anomalies[1].Key)
+}
+
+func TestSize(t *testing.T) {
+	c := CreateChecker(learn(t), Options{})
+	at := start.Add(24 * time.Hour)
+
+	check(c, at, "/users", "2xx", 50000, 11)
+
+	anomalies := c.Flush()
+
+	assert.Len(t, anomalies, 1)
+	assert.Equal(t, "size", anomalies[0].Kind)
+	assert.Equal(t, 50000.0, anomalies[0].Value)
+}
+
+func TestOtherHours(t *testing.T) {
+	c := CreateChecker(learn(t), Options{})
+
+	// 3am has no logs, so every hour is used
+	check(c, start.Add(-10*time.Hour), "/users", "2xx", 1000, 11)
+
+	assert.Empty(t, c.Flush())
+}
+
+func TestTickFlagsOutages(t *testing.T) {
+	c := CreateChecker(learn(t), Options{Threshold: 3})
+	at := start.Add(24 * time.Hour)
+
+	check(c, at, "/users", "2xx", 1000, 11)
+
+	now := time.Now()
+
+	assert.Empty(t, c.Tick(now))
+	assert.Empty(t, c.Tick(now.Add(2*time.Minute)))
+
+	// nothing arrived in the next minute
+	anomalies := c.Tick(now.Add(3 * time.Minute))
+
+	assert.Len(t, anomalies, 3)
+	assert.Equal(t, Anomaly{Start: at.Add(time.Minute), Kind: "total", Value: 0, Expected: 12, Score: anomalies[0].Score}, anomalies[0])
+	assert.Equal(t, "/users", anomalies[1].Key)
+	assert.Equal(t, "2xx", anomalies[2].Key)
+
+	// the bucket started by the clock is empty, so it's not judged
+	assert.Empty(t, c.Flush())
+}
+
+func TestTickWithoutLogs(t *testing.T) {
+	c := CreateChecker(learn(t), Options{})
+
+	assert.Empty(t, c.Tick(time.Now()))
+	assert.Empty(t, c.Flush())
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	p := learn(t)
+	path := filepath.Join(t.TempDir(), "baseline.json")
+
+	assert.Nil(t, p.Save(path))
+
+	loaded, err := Load(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, loaded.bucket)
+	assert.Equal(t, p.Hours, loaded.Hours)
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/marcos-venicius/quang"
+	"golang.org/x/term"
+)
+
+// how many logs are kept by default, the oldest ones are dropped after it
+const DEFAULT_BROWSE_BUFFER = 100000
+
+const (
+	browse_mode_normal = iota
+	browse_mode_query
+	browse_mode_export
+)
+
+type entry_t struct {
+	log log_t
+	// the log formatted with -f or -template, computed once
+	line string
+}
+
+type browser_t struct {
+	tokens   []string
+	template *template.Template
+
+	// a ring of the last logs, entry n is at n % capacity
+	entries  []entry_t
+	capacity int
+	// how many logs were received, so the oldest one kept is next - len(entries)
+	next int
+	// logs that could not be parsed
+	invalid int
+
+	query            string
+	q                *quang.Quang
+	dynamicVariables []string
+	// the numbers of the entries matching the query, in order
+	view []int
+
+	// the first line of the view on the screen
+	offset int
+	// keeps showing the last logs as they arrive
+	follow bool
+
+	mode  int
+	input []rune
+	// the query before the edition, restored by esc
+	previous string
+	err      string
+	message  string
+	done     bool
+	// new logs arrived since the last render
+	dirty bool
+
+	mutex sync.Mutex
+}
+
+func createBrowser(capacity int, tokens []string, template *template.Template, query string, q *quang.Quang) *browser_t {
+	return &browser_t{
+		tokens:           tokens,
+		template:         template,
+		entries:          make([]entry_t, 0, min(capacity, 1024)),
+		capacity:         capacity,
+		query:            query,
+		q:                q,
+		dynamicVariables: dynamicQueryVariables(query),
+		view:             make([]int, 0),
+		follow:           true,
+	}
+}
+
+func (b *browser_t) entry(n int) *entry_t {
+	return &b.entries[n%b.capacity]
+}
+
+func (b *browser_t) oldest() int {
+	return b.next - len(b.entries)
+}
+
+func (b *browser_t) matches(log log_t) bool {
+	log.bindQueryVariables(b.q, b.dynamicVariables)
+
+	show, err := b.q.Eval()
+
+	if err != nil {
+		b.err = err.Error()
+	}
+
+	return show
+}
+
+func (b *browser_t) add(log log_t) {
+	entry := entry_t{log: log, line: formatLine(b.tokens, b.template, log)}
+
+	// the kept logs don't keep their values too, they are built again when needed
+	entry.log.cache = nil
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.entries) < b.capacity {
+		b.entries = append(b.entries, entry)
+	} else {
+		*b.entry(b.next) = entry
+	}
+
+	b.next++
+	b.dirty = true
+
+	// the dropped entries leave the view too
+	dropped := 0
+
+	for dropped < len(b.view) && b.view[dropped] < b.oldest() {
+		dropped++
+	}
+
+	if dropped > 0 {
+		b.view = b.view[dropped:]
+		b.offset = max(b.offset-dropped, 0)
+	}
+
+	if b.matches(log) {
+		b.view = append(b.view, b.next-1)
+	}
+}
+
+func (b *browser_t) invalidLine() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.invalid++
+	b.dirty = true
+}
+
+// evaluates every log kept with the query again
+func (b *browser_t) apply(query string, q *quang.Quang) {
+	b.query = query
+	b.q = q
+	b.dynamicVariables = dynamicQueryVariables(query)
+	b.err = ""
+	b.view = b.view[:0]
+
+	for n := b.oldest(); n < b.next; n++ {
+		if b.matches(b.entry(n).log) {
+			b.view = append(b.view, n)
+		}
+	}
+
+	b.offset = 0
+	b.follow = true
+}
+
+// the query is evaluated as it is typed, and the syntax errors are
+// shown while it's not valid
+func (b *browser_t) edit() {
+	query := string(b.input)
+	q, err := compileQuery(query)
+
+	if err != nil {
+		b.err = err.Error()
+		return
+	}
+
+	b.apply(query, q)
+}
+
+func (b *browser_t) export(path string) {
+	file, err := os.Create(path)
+
+	if err != nil {
+		b.err = err.Error()
+		return
+	}
+
+	writer := bufio.NewWriter(file)
+
+	for _, n := range b.view {
+		writer.WriteString(b.entry(n).line)
+		writer.WriteString("\n")
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		b.err = err.Error()
+		return
+	}
+
+	if err := file.Close(); err != nil {
+		b.err = err.Error()
+		return
+	}
+
+	b.err = ""
+	b.message = fmt.Sprintf("exported %d logs to %s", len(b.view), path)
+}
+
+func (b *browser_t) scroll(lines int, height int) {
+	last := max(len(b.view)-height, 0)
+
+	b.offset = min(max(b.offset+lines, 0), last)
+	b.follow = b.offset == last
+}
+
+// handles a key pressed in the terminal, height is the size of the pane
+func (b *browser_t) key(key string, height int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.message = ""
+
+	if key == "\x03" {
+		b.done = true
+		return
+	}
+
+	if b.mode != browse_mode_normal {
+		switch key {
+		case "\r", "\n":
+			if b.mode == browse_mode_export && len(b.input) > 0 {
+				b.export(string(b.input))
+			} else if b.mode == browse_mode_query && len(b.err) > 0 {
+				// an invalid query stays in the prompt to be fixed
+				return
+			}
+
+			b.mode = browse_mode_normal
+		case key_escape:
+			if b.mode == browse_mode_query && b.query != b.previous {
+				q, _ := compileQuery(b.previous)
+
+				b.apply(b.previous, q)
+			}
+
+			b.err = ""
+			b.mode = browse_mode_normal
+		case "\x7f", "\b":
+			if len(b.input) > 0 {
+				b.input = b.input[:len(b.input)-1]
+
+				if b.mode == browse_mode_query {
+					b.edit()
+				}
+			}
+		default:
+			if key[0] >= ' ' {
+				b.input = append(b.input, []rune(key)...)
+
+				if b.mode == browse_mode_query {
+					b.edit()
+				}
+			}
+		}
+
+		return
+	}
+
+	switch key {
+	case "j", key_down:
+		b.scroll(1, height)
+	case "k", key_up:
+		b.scroll(-1, height)
+	case " ", "f", key_page_down:
+		b.scroll(height, height)
+	case "b", key_page_up:
+		b.scroll(-height, height)
+	case "g", key_home:
+		b.scroll(-len(b.view), height)
+	case "G", key_end:
+		b.scroll(len(b.view), height)
+	case "/":
+		b.mode = browse_mode_query
+		b.input = []rune(b.query)
+		b.previous = b.query
+	case "e":
+		b.mode = browse_mode_export
+		b.input = []rune{}
+	case "q":
+		b.done = true
+	}
+}
+
+// the lines of the screen
+func (b *browser_t) render(width int, height int) []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.dirty = false
+
+	lines := make([]string, 0, height)
+	pane := max(height-2, 1)
+
+	if b.follow {
+		b.offset = max(len(b.view)-pane, 0)
+	}
+
+	query := b.query
+
+	if len(query) == 0 {
+		query = "(everything)"
+	}
+
+	header := fmt.Sprintf("lfi browse  %d of %d logs  query: %s", len(b.view), len(b.entries), query)
+
+	if b.invalid > 0 {
+		header += fmt.Sprintf("  (%d invalid lines)", b.invalid)
+	}
+
+	lines = append(lines, "\x1b[7m"+fit(header, width)+"\x1b[0m")
+
+	for i := b.offset; i < len(b.view) && i < b.offset+pane; i++ {
+		lines = append(lines, fit(b.entry(b.view[i]).line, width))
+	}
+
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	switch {
+	case b.mode == browse_mode_query && len(b.err) > 0:
+		lines = append(lines, "query: "+string(b.input)+"█  \x1b[31m"+b.err+"\x1b[0m")
+	case b.mode == browse_mode_query:
+		lines = append(lines, "query: "+string(b.input)+"█")
+	case b.mode == browse_mode_export:
+		lines = append(lines, "export to: "+string(b.input)+"█")
+	case len(b.err) > 0:
+		lines = append(lines, "\x1b[31m"+fit(b.err, width)+"\x1b[0m")
+	case len(b.message) > 0:
+		lines = append(lines, fit(b.message, width))
+	default:
+		lines = append(lines, "\x1b[7m j k \x1b[0m scroll  \x1b[7m space b \x1b[0m page  \x1b[7m g G \x1b[0m top/bottom  \x1b[7m / \x1b[0m query  \x1b[7m e \x1b[0m export  \x1b[7m q \x1b[0m quit")
+	}
+
+	return lines
+}
+
+func runBrowse(args []string) int {
+	flags := flag.NewFlagSet("browse", flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of lfi browse: lfi browse [flags] [file]\n\nbrowses the logs of stdin, or of file, filtering them with a query edited with / while browsing\n\n")
+		flags.PrintDefaults()
+	}
+
+	common := addLogFlags(flags)
+	format := flags.String("f", "", "format the log in a specific way. the config format by default")
+	templateText := flags.String("template", "", "format the log using a go text/template. it takes precedence over -f and the config format")
+	capacity := flags.Int("buffer", DEFAULT_BROWSE_BUFFER, "how many logs are kept, the oldest ones are dropped after it")
+
+	flags.Parse(args)
+
+	configs, q, _, err := common.load()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	if *capacity < 1 {
+		fmt.Fprintln(os.Stderr, "error: -buffer should be at least 1")
+		return 1
+	}
+
+	formatting := configs.format
+
+	if len(*format) > 0 {
+		formatting = *format
+	}
+
+	logFormatter := createLogFormatter(configs)
+
+	tokens, err := logFormatter.ParseFormatString(formatting)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	var logTemplate *template.Template
+
+	templateSource := configs.template
+
+	if len(*format) > 0 {
+		templateSource = ""
+	}
+
+	if len(*templateText) > 0 {
+		templateSource = *templateText
+	}
+
+	if len(templateSource) > 0 {
+		logTemplate, err = parseTemplate(templateSource)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			return 1
+		}
+	}
+
+	var input io.Reader = os.Stdin
+
+	switch flags.NArg() {
+	case 0:
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Fprintln(os.Stderr, "error: lfi browse needs logs. pipe them to stdin, like \"cat access.log | lfi browse\", or give a file")
+			return 1
+		}
+	case 1:
+		file, err := os.Open(flags.Arg(0))
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			return 1
+		}
+
+		defer file.Close()
+
+		input = file
+	default:
+		flags.Usage()
+		return 1
+	}
+
+	terminal, err := openTerminal()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: lfi browse needs a terminal: %s\n", err.Error())
+		return 1
+	}
+
+	defer terminal.close()
+
+	browser := createBrowser(*capacity, tokens, logTemplate, *common.query, q)
+
+	logs := make(chan []byte, 1024)
+
+	go func() {
+		readLines(input, logs)
+		close(logs)
+	}()
+
+	go func() {
+		defer terminal.recoverPanic()
+
+		for line := range logs {
+			if log, err := parseKongLogLine(string(line), *common.breakParamsOut, configs); err == nil {
+				browser.add(log)
+			} else {
+				browser.invalidLine()
+			}
+		}
+	}()
+
+	keys := terminal.keys()
+
+	// the new logs are shown a few times per second, not one by one
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	width, height := terminal.size()
+
+	terminal.draw(browser.render(width, height))
+
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return 0
+			}
+
+			browser.key(key, max(height-2, 1))
+
+			browser.mutex.Lock()
+			done := browser.done
+			browser.mutex.Unlock()
+
+			if done {
+				return 0
+			}
+
+			width, height = terminal.size()
+
+			terminal.draw(browser.render(width, height))
+		case <-ticker.C:
+			browser.mutex.Lock()
+			dirty := browser.dirty
+			browser.mutex.Unlock()
+
+			width, height = terminal.size()
+
+			if dirty {
+				terminal.draw(browser.render(width, height))
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrowseEdit(t *testing.T) {
+	q, _ := compileQuery("")
+	b := createBrowser(10, []string{"%resource"}, nil, "", q)
+
+	for _, resource := range []string{"/api/users", "/login", "/api/orders"} {
+		b.add(log_t{resource: resource})
+	}
+
+	b.input = []rune("resource reg '^/api'")
+	b.edit()
+
+	assert.Equal(t, "", b.err)
+	assert.Equal(t, []int{0, 2}, b.view)
+
+	// an invalid pattern keeps the last view and shows why in the prompt
+	b.input = []rune("resource reg '^/api('")
+	b.edit()
+
+	assert.Equal(t, "invalid pattern '^/api(': error parsing regexp: missing closing ): `^/api(`", b.err)
+	assert.Equal(t, []int{0, 2}, b.view)
+}
@@ -0,0 +1,217 @@
+package bruteforce
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_THRESHOLD       = 10
+	DEFAULT_ROUTE_THRESHOLD = 100
+	DEFAULT_WINDOW          = time.Minute
+	// the ip of the offenders that are a route as a whole, attacked from many
+	// ips at once, like in credential stuffing
+	ALL_IPS = "*"
+)
+
+// the login routes used when none is configured
+var DefaultRoutes = []string{
+	"/login",
+	"/logon",
+	"/signin",
+	"/sign-in",
+	"/sign_in",
+	"/session",
+	"/sessions",
+	"/token",
+	"/oauth/token",
+	"/auth/*",
+	"/users/sign_in",
+	"/wp-login.php",
+	"/xmlrpc.php",
+	"/api/login",
+	"/api/auth/*",
+	"/api/{version}/login",
+	"/api/{version}/auth/*",
+}
+
+// the statuses of the failed logins used when none is configured
+var DefaultFailures = []int{401, 403}
+
+type Options struct {
+	// failures of an ip on a route, within the window, that flag it
+	Threshold int
+	// failures of a route, from every ip, within the window, that flag it
+	RouteThreshold int
+	Window         time.Duration
+}
+
+// Offender is an ip, or every ip, with too many failures on a route
+type Offender struct {
+	// ALL_IPS when the failures of every ip are counted together
+	IP       string
+	Route    string
+	Requests int
+	Failures int
+	// the most failures within a window
+	Peak int
+	// when the failures went over the threshold for the first time, zero
+	// when they never did
+	Flagged time.Time
+	First   time.Time
+	Last    time.Time
+}
+
+// how many of the requests failed, from 0 to 1
+func (o Offender) Rate() float64 {
+	if o.Requests == 0 {
+		return 0
+	}
+
+	return float64(o.Failures) / float64(o.Requests)
+}
+
+type key_t struct {
+	ip    string
+	route string
+}
+
+type tracker_t struct {
+	offender  Offender
+	threshold int
+	// the times of the failures within the window
+	failures []time.Time
+}
+
+// Detector counts the failures of each ip and route within a sliding window
+type Detector struct {
+	options  Options
+	trackers map[key_t]*tracker_t
+	mutex    sync.Mutex
+}
+
+func Create(options Options) *Detector {
+	if options.Threshold < 1 {
+		options.Threshold = DEFAULT_THRESHOLD
+	}
+
+	if options.RouteThreshold < 1 {
+		options.RouteThreshold = DEFAULT_ROUTE_THRESHOLD
+	}
+
+	if options.Window <= 0 {
+		options.Window = DEFAULT_WINDOW
+	}
+
+	return &Detector{
+		options:  options,
+		trackers: make(map[key_t]*tracker_t),
+	}
+}
+
+func (d *Detector) tracker(ip string, route string, threshold int) *tracker_t {
+	key := key_t{ip: ip, route: route}
+	tracker, ok := d.trackers[key]
+
+	if !ok {
+		tracker = &tracker_t{offender: Offender{IP: ip, Route: route}, threshold: threshold}
+		d.trackers[key] = tracker
+	}
+
+	return tracker
+}
+
+func (t *tracker_t) add(failed bool, at time.Time, window time.Duration) {
+	o := &t.offender
+
+	if o.First.IsZero() || at.Before(o.First) {
+		o.First = at
+	}
+
+	if at.After(o.Last) {
+		o.Last = at
+	}
+
+	o.Requests++
+
+	if !failed {
+		return
+	}
+
+	o.Failures++
+
+	// the failures are kept in order, so logs a bit out of order, like the
+	// ones of files read one after the other, still count. the ones older
+	// than the window of the newest failure can't change any window
+	if len(t.failures) > 0 && !at.After(t.failures[len(t.failures)-1].Add(-window)) {
+		return
+	}
+
+	position := sort.Search(len(t.failures), func(i int) bool { return t.failures[i].After(at) })
+	t.failures = slices.Insert(t.failures, position, at)
+
+	start := t.failures[len(t.failures)-1].Add(-window)
+	expired := 0
+
+	for expired < len(t.failures) && !t.failures[expired].After(start) {
+		expired++
+	}
+
+	if expired > 0 {
+		t.failures = append(t.failures[:0], t.failures[expired:]...)
+	}
+
+	o.Peak = max(o.Peak, len(t.failures))
+
+	// the failures are within a window, so it went over the threshold with
+	// the one after the first threshold ones
+	if o.Flagged.IsZero() && len(t.failures) > t.threshold {
+		o.Flagged = t.failures[t.threshold]
+	}
+}
+
+// Add counts a request of ip to a login route at t, which is usually the
+// time of the log so old logs are judged by their own time
+func (d *Detector) Add(ip string, route string, failed bool, t time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.tracker(ip, route, d.options.Threshold).add(failed, t, d.options.Window)
+	d.tracker(ALL_IPS, route, d.options.RouteThreshold).add(failed, t, d.options.Window)
+}
+
+// Offenders returns the flagged ips and routes, with the biggest peaks first
+func (d *Detector) Offenders() []Offender {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	offenders := make([]Offender, 0)
+
+	for _, tracker := range d.trackers {
+		if !tracker.offender.Flagged.IsZero() {
+			offenders = append(offenders, tracker.offender)
+		}
+	}
+
+	sort.Slice(offenders, func(i, j int) bool {
+		a, b := offenders[i], offenders[j]
+
+		if a.Peak != b.Peak {
+			return a.Peak > b.Peak
+		}
+
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+
+		if a.IP != b.IP {
+			return a.IP < b.IP
+		}
+
+		return a.Route < b.Route
+	})
+
+	return offenders
+}
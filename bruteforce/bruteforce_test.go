@@ -0,0 +1,130 @@
+package bruteforce
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2024, 10, 10, 13, 0, 0, 0, time.UTC)
+
+func TestFlagsOverThreshold(t *testing.T) {
+	d := Create(Options{Threshold: 3, RouteThreshold: 100, Window: 10 * time.Second})
+
+	for i := range 3 {
+		d.Add("10.0.0.1", "/login", true, start.Add(time.Duration(i)*time.Second))
+	}
+
+	assert.Empty(t, d.Offenders())
+
+	d.Add("10.0.0.1", "/login", true, start.Add(3*time.Second))
+
+	offenders := d.Offenders()
+
+	assert.Len(t, offenders, 1)
+	assert.Equal(t, "10.0.0.1", offenders[0].IP)
+	assert.Equal(t, "/login", offenders[0].Route)
+	assert.Equal(t, 4, offenders[0].Peak)
+	assert.Equal(t, start.Add(3*time.Second), offenders[0].Flagged)
+	assert.Equal(t, start, offenders[0].First)
+}
+
+func TestSlowFailuresAreNotFlagged(t *testing.T) {
+	d := Create(Options{Threshold: 3, RouteThreshold: 100, Window: 10 * time.Second})
+
+	for i := range 20 {
+		d.Add("10.0.0.1", "/login", true, start.Add(time.Duration(i)*5*time.Second))
+	}
+
+	assert.Empty(t, d.Offenders())
+}
+
+func TestOutOfOrderFailures(t *testing.T) {
+	d := Create(Options{Threshold: 3, RouteThreshold: 100, Window: 10 * time.Second})
+
+	// 4 failures within 10s, read out of order
+	for _, second := range []int{8, 2, 5, 0} {
+		d.Add("10.0.0.1", "/login", true, start.Add(time.Duration(second)*time.Second))
+	}
+
+	offenders := d.Offenders()
+
+	assert.Len(t, offenders, 1)
+	assert.Equal(t, 4, offenders[0].Peak)
+	assert.Equal(t, start.Add(8*time.Second), offenders[0].Flagged)
+	assert.Equal(t, start, offenders[0].First)
+	assert.Equal(t, start.Add(8*time.Second), offenders[0].Last)
+}
+
+func TestFailuresOlderThanTheWindow(t *testing.T) {
+	d := Create(Options{Threshold: 3, RouteThreshold: 100, Window: 10 * time.Second})
+
+	d.Add("10.0.0.1", "/login", true, start.Add(time.Minute))
+
+	// they are a minute older than the newest failure, so they are only counted
+	for i := range 5 {
+		d.Add("10.0.0.1", "/login", true, start.Add(time.Duration(i)*time.Second))
+	}
+
+	assert.Empty(t, d.Offenders())
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	offender := d.trackers[key_t{ip: "10.0.0.1", route: "/login"}].offender
+
+	assert.Equal(t, 6, offender.Failures)
+	assert.Equal(t, 1, offender.Peak)
+}
+
+func TestSuccessesAreOnlyRequests(t *testing.T) {
+	d := Create(Options{Threshold: 2, RouteThreshold: 100, Window: time.Minute})
+
+	for i := range 10 {
+		d.Add("10.0.0.1", "/login", i%3 == 0, start.Add(time.Duration(i)*time.Second))
+	}
+
+	offenders := d.Offenders()
+
+	assert.Len(t, offenders, 1)
+	assert.Equal(t, 10, offenders[0].Requests)
+	assert.Equal(t, 4, offenders[0].Failures)
+	assert.Equal(t, 0.4, offenders[0].Rate())
+	assert.Equal(t, start.Add(6*time.Second), offenders[0].Flagged)
+	assert.Equal(t, start.Add(9*time.Second), offenders[0].Last)
+}
+
+func TestRouteFromManyIPs(t *testing.T) {
+	d := Create(Options{Threshold: 3, RouteThreshold: 5, Window: time.Minute})
+
+	for i := range 6 {
+		d.Add(string(rune('a'+i)), "/login", true, start.Add(time.Duration(i)*time.Second))
+	}
+
+	offenders := d.Offenders()
+
+	assert.Len(t, offenders, 1)
+	assert.Equal(t, ALL_IPS, offenders[0].IP)
+	assert.Equal(t, 6, offenders[0].Peak)
+}
+
+func TestOffendersAreRanked(t *testing.T) {
+	d := Create(Options{Threshold: 1, RouteThreshold: 100, Window: time.Minute})
+
+	for i := range 5 {
+		d.Add("10.0.0.2", "/login", true, start.Add(time.Duration(i)*time.Second))
+	}
+
+	for i := range 2 {
+		d.Add("10.0.0.1", "/login", true, start.Add(time.Duration(i)*time.Second))
+		d.Add("10.0.0.1", "/oauth/token", true, start.Add(time.Duration(i)*time.Second))
+	}
+
+	offenders := d.Offenders()
+
+	assert.Len(t, offenders, 3)
+	assert.Equal(t, "10.0.0.2", offenders[0].IP)
+	assert.Equal(t, "/login", offenders[1].Route)
+	assert.Equal(t, "/oauth/token", offenders[2].Route)
+}
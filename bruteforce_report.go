@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marcos-venicius/lfi/bruteforce"
+	"github.com/marcos-venicius/lfi/route"
+)
+
+// bruteforce_report_t finds the ips failing to login too often, for -bruteforce
+type bruteforce_report_t struct {
+	detector *bruteforce.Detector
+	routes   *route.Matcher
+	failures []int
+	top      int
+	output   string
+}
+
+func createBruteforceReport(configs *Configs, options bruteforce.Options, top int, output string) *bruteforce_report_t {
+	routes := configs.loginRoutes
+
+	if routes == nil {
+		matcher := route.CreateMatcher()
+
+		for _, pattern := range bruteforce.DefaultRoutes {
+			matcher.Add(pattern)
+		}
+
+		routes = &matcher
+	}
+
+	failures := configs.loginFailures
+
+	if failures == nil {
+		failures = bruteforce.DefaultFailures
+	}
+
+	return &bruteforce_report_t{
+		detector: bruteforce.Create(options),
+		routes:   routes,
+		failures: failures,
+		top:      top,
+		output:   output,
+	}
+}
+
+func (r *bruteforce_report_t) add(log log_t) {
+	pattern, ok := r.routes.Match(log.path)
+
+	if !ok {
+		return
+	}
+
+	r.detector.Add(log.ip, pattern, slices.Contains(r.failures, int(log.statusCode)), log.judgedTime())
+}
+
+func (r *bruteforce_report_t) write(w io.Writer) error {
+	offenders := r.detector.Offenders()
+
+	if r.top > 0 && len(offenders) > r.top {
+		offenders = offenders[:r.top]
+	}
+
+	columns := []string{"ip", "route", "requests", "failures", "rate", "peak", "flagged", "first", "last"}
+	display := func(t time.Time) string {
+		return t.Format(logTimeLayout)
+	}
+
+	switch r.output {
+	case "json":
+		rows := make([]map[string]any, 0, len(offenders))
+
+		for _, o := range offenders {
+			rows = append(rows, map[string]any{
+				"ip":       o.IP,
+				"route":    o.Route,
+				"requests": o.Requests,
+				"failures": o.Failures,
+				"rate":     o.Rate(),
+				"peak":     o.Peak,
+				"flagged":  display(o.Flagged),
+				"first":    display(o.First),
+				"last":     display(o.Last),
+			})
+		}
+
+		return json.NewEncoder(w).Encode(rows)
+	case "csv":
+		writer := csv.NewWriter(w)
+
+		writer.Write(columns)
+
+		for _, o := range offenders {
+			writer.Write([]string{o.IP, o.Route, fmt.Sprint(o.Requests), fmt.Sprint(o.Failures), fmt.Sprintf("%.2f", o.Rate()), fmt.Sprint(o.Peak), display(o.Flagged), display(o.First), display(o.Last)})
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "%s\n", strings.ToUpper(strings.Join(columns, "\t")))
+
+	for _, o := range offenders {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%.0f%%\t%d\t%s\t%s\t%s\n", o.IP, o.Route, o.Requests, o.Failures, o.Rate()*100, o.Peak, display(o.Flagged), display(o.First), display(o.Last))
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const barWidth = 40
+
+// a longer run of empty buckets, like the night in a file of days, is left
+// out of the timeline instead of printing a row for every one of them
+const maxEmptyBuckets = 60
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+type bucket_t struct {
+	// every log of the bucket, with the -agg accumulators
+	total *group_t
+	// how many logs each value of the split field has
+	splits map[string]int
+}
+
+type bucketer_t struct {
+	size   time.Duration
+	split  string
+	aggs   []agg_t
+	output string
+
+	buckets  map[int64]*bucket_t
+	splits   map[string]struct{}
+	location *time.Location
+	// logs without a valid time
+	skipped int
+	mutex   sync.Mutex
+}
+
+func createBucketer(size time.Duration, split string, aggs []agg_t, output string) *bucketer_t {
+	return &bucketer_t{
+		size:    size,
+		split:   split,
+		aggs:    aggs,
+		output:  output,
+		buckets: make(map[int64]*bucket_t),
+		splits:  make(map[string]struct{}),
+	}
+}
+
+func (b *bucketer_t) add(log log_t) {
+	t := log.at
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if t.IsZero() {
+		b.skipped++
+		return
+	}
+
+	if b.location == nil {
+		b.location = t.Location()
+	}
+
+	start := bucketStart(t, b.size)
+
+	bucket, ok := b.buckets[start]
+
+	if !ok {
+		bucket = b.createBucket()
+		b.buckets[start] = bucket
+	}
+
+	values := log.values()
+
+	bucket.total.count++
+
+	bucket.total.accumulate(b.aggs, log, values)
+
+	if len(b.split) > 0 {
+		value := fmt.Sprint(log.field(b.split, values))
+
+		bucket.splits[value]++
+		b.splits[value] = struct{}{}
+	}
+}
+
+// the start of the bucket of t, aligned in the offset of the log, so the
+// buckets of a day start at the midnight of the log and not of utc
+func bucketStart(t time.Time, size time.Duration) int64 {
+	_, offset := t.Zone()
+	seconds := int64(size / time.Second)
+	local := t.Unix() + int64(offset)
+
+	return local - local%seconds - int64(offset)
+}
+
+// every bucket between the first and the last one, including the empty ones
+// of the gaps of up to maxEmptyBuckets
+func (b *bucketer_t) timeline() []int64 {
+	starts := make([]int64, 0, len(b.buckets))
+
+	for start := range b.buckets {
+		starts = append(starts, start)
+	}
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	seconds := int64(b.size / time.Second)
+	timeline := make([]int64, 0, len(starts))
+
+	for i, start := range starts {
+		if i > 0 && (start-starts[i-1])/seconds-1 <= maxEmptyBuckets {
+			for empty := starts[i-1] + seconds; empty < start; empty += seconds {
+				timeline = append(timeline, empty)
+			}
+		}
+
+		timeline = append(timeline, start)
+	}
+
+	return timeline
+}
+
+func (b *bucketer_t) splitValues() []string {
+	values := make([]string, 0, len(b.splits))
+
+	for value := range b.splits {
+		values = append(values, value)
+	}
+
+	sort.Strings(values)
+
+	return values
+}
+
+func (b *bucketer_t) label(start int64) string {
+	return time.Unix(start, 0).In(b.location).Format(time.DateTime)
+}
+
+func (b *bucketer_t) createBucket() *bucket_t {
+	bucket := &bucket_t{
+		total:  &group_t{accumulators: make([]accumulator_t, len(b.aggs))},
+		splits: make(map[string]int),
+	}
+
+	for i, agg := range b.aggs {
+		bucket.total.accumulators[i] = agg.create()
+	}
+
+	return bucket
+}
+
+// the bucket starting at start, or an empty one for the gaps of the timeline
+func (b *bucketer_t) bucket(start int64) *bucket_t {
+	if bucket, ok := b.buckets[start]; ok {
+		return bucket
+	}
+
+	return b.createBucket()
+}
+
+func sparkline(counts []int) string {
+	highest := 0
+
+	for _, count := range counts {
+		highest = max(highest, count)
+	}
+
+	var builder strings.Builder
+
+	for _, count := range counts {
+		if highest == 0 {
+			builder.WriteRune(sparks[0])
+			continue
+		}
+
+		builder.WriteRune(sparks[count*(len(sparks)-1)/highest])
+	}
+
+	return builder.String()
+}
+
+func (b *bucketer_t) write(w io.Writer) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	timeline := b.timeline()
+	splits := b.splitValues()
+
+	columns := []string{"time", "count"}
+	columns = append(columns, splits...)
+
+	for _, agg := range b.aggs {
+		columns = append(columns, agg.name)
+	}
+
+	switch b.output {
+	case "json":
+		rows := make([]map[string]any, 0, len(timeline))
+
+		for _, start := range timeline {
+			bucket := b.bucket(start)
+			row := map[string]any{
+				"time":  time.Unix(start, 0).In(b.location).Format(time.RFC3339),
+				"count": bucket.total.count,
+			}
+
+			if len(b.split) > 0 {
+				split := make(map[string]int)
+
+				for _, value := range splits {
+					split[value] = bucket.splits[value]
+				}
+
+				row[b.split] = split
+			}
+
+			for i, agg := range b.aggs {
+				row[agg.name] = bucket.total.accumulators[i].result()
+			}
+
+			rows = append(rows, row)
+		}
+
+		return json.NewEncoder(w).Encode(rows)
+	case "csv":
+		writer := csv.NewWriter(w)
+
+		writer.Write(columns)
+
+		for _, start := range timeline {
+			bucket := b.bucket(start)
+			row := []string{time.Unix(start, 0).In(b.location).Format(time.RFC3339), fmt.Sprint(bucket.total.count)}
+
+			for _, value := range splits {
+				row = append(row, fmt.Sprint(bucket.splits[value]))
+			}
+
+			for _, accumulator := range bucket.total.accumulators {
+				row = append(row, displayResult(accumulator.result()))
+			}
+
+			writer.Write(row)
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	}
+
+	highest := 0
+	counts := make([]int, 0, len(timeline))
+
+	for _, start := range timeline {
+		count := b.bucket(start).total.count
+
+		highest = max(highest, count)
+		counts = append(counts, count)
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "%s\t\n", strings.ToUpper(strings.Join(columns, "\t")))
+
+	for _, start := range timeline {
+		bucket := b.bucket(start)
+
+		fmt.Fprintf(writer, "%s\t%d\t", b.label(start), bucket.total.count)
+
+		for _, value := range splits {
+			fmt.Fprintf(writer, "%d\t", bucket.splits[value])
+		}
+
+		for _, accumulator := range bucket.total.accumulators {
+			fmt.Fprintf(writer, "%s\t", displayResult(accumulator.result()))
+		}
+
+		size := 0
+
+		if highest > 0 {
+			size = bucket.total.count * barWidth / highest
+		}
+
+		fmt.Fprintf(writer, "%s\n", strings.Repeat("█", size))
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if len(counts) > 0 {
+		fmt.Fprintf(w, "\n%s\n", sparkline(counts))
+	}
+
+	if b.skipped > 0 {
+		fmt.Fprintf(w, "%d logs without a valid time were ignored\n", b.skipped)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcos-venicius/quang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketStart(t *testing.T) {
+	saoPaulo := time.FixedZone("", -3*60*60)
+
+	cases := []struct {
+		time     time.Time
+		size     time.Duration
+		expected time.Time
+	}{
+		{time.Date(2024, 10, 10, 13, 55, 36, 0, time.UTC), time.Minute, time.Date(2024, 10, 10, 13, 55, 0, 0, time.UTC)},
+		{time.Date(2024, 10, 10, 13, 55, 36, 0, time.UTC), 15 * time.Minute, time.Date(2024, 10, 10, 13, 45, 0, 0, time.UTC)},
+		{time.Date(2024, 10, 10, 1, 0, 0, 0, saoPaulo), 24 * time.Hour, time.Date(2024, 10, 10, 0, 0, 0, 0, saoPaulo)},
+		{time.Date(2024, 10, 10, 23, 59, 59, 0, saoPaulo), 24 * time.Hour, time.Date(2024, 10, 10, 0, 0, 0, 0, saoPaulo)},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected.Unix(), bucketStart(c.time, c.size), c.time.String())
+	}
+}
+
+func bucketed(size time.Duration, split string, output string, times ...string) string {
+	bucketer := createBucketer(size, split, nil, output)
+	methods := []quang.AtomType{http_get_atom, http_post_atom}
+
+	for i, t := range times {
+		at, _ := time.Parse(logTimeLayout, t)
+
+		bucketer.add(log_t{time: t, at: at, method: methods[i%2]})
+	}
+
+	var builder strings.Builder
+
+	bucketer.write(&builder)
+
+	return builder.String()
+}
+
+func TestBucketer(t *testing.T) {
+	output := bucketed(time.Minute, "method", "csv",
+		"10/Oct/2024:13:55:36 -0300",
+		"10/Oct/2024:13:55:50 -0300",
+		"10/Oct/2024:13:57:01 -0300",
+		"invalid",
+	)
+
+	assert.Equal(t, "time,count,GET,POST\n"+
+		"2024-10-10T13:55:00-03:00,2,1,1\n"+
+		"2024-10-10T13:56:00-03:00,0,0,0\n"+
+		"2024-10-10T13:57:00-03:00,1,1,0\n", output)
+
+	table := bucketed(time.Minute, "", "table", "10/Oct/2024:13:55:36 +0000", "10/Oct/2024:13:56:36 +0000", "10/Oct/2024:13:56:40 +0000")
+
+	assert.Contains(t, table, "2024-10-10 13:55:00  1      ████████████████████\n")
+	assert.Contains(t, table, "2024-10-10 13:56:00  2      ████████████████████████████████████████\n")
+	assert.Contains(t, table, "\n▄█\n")
+}
+
+func TestTimelineGaps(t *testing.T) {
+	// an hour later only a bucket is kept, instead of 59 empty ones, and a
+	// day later too
+	output := bucketed(time.Minute, "", "csv",
+		"10/Oct/2024:13:55:00 +0000",
+		"10/Oct/2024:13:58:00 +0000",
+		"10/Oct/2024:15:00:00 +0000",
+		"11/Oct/2024:15:00:00 +0000",
+	)
+
+	assert.Equal(t, "time,count\n"+
+		"2024-10-10T13:55:00Z,1\n"+
+		"2024-10-10T13:56:00Z,0\n"+
+		"2024-10-10T13:57:00Z,0\n"+
+		"2024-10-10T13:58:00Z,1\n"+
+		"2024-10-10T15:00:00Z,1\n"+
+		"2024-10-11T15:00:00Z,1\n", output)
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▄█", sparkline([]int{0, 5, 10}))
+	assert.Equal(t, "▁▁", sparkline([]int{0, 0}))
+}
@@ -0,0 +1,164 @@
+package compare
+
+import (
+	"math"
+
+	"github.com/marcos-venicius/lfi/sketch"
+)
+
+const (
+	// how many standard errors away a proportion should move to be a change
+	SCORE = 3
+	// the smallest relative change of the share of the requests, 25%
+	MIN_TRAFFIC_CHANGE = 0.25
+	// the smallest change of the error rate, one percentage point
+	MIN_ERROR_CHANGE = 0.01
+	// the smallest relative change of a percentile, 50%
+	MIN_PERCENTILE_CHANGE = 0.5
+	// percentiles of fewer values are never compared
+	MIN_VALUES = 20
+)
+
+// the changes of a group, from before to after
+const (
+	NEW     = "new"
+	GONE    = "gone"
+	TRAFFIC = "traffic"
+	ERRORS  = "errors"
+	P50     = "p50"
+	P95     = "p95"
+)
+
+// Side is a group in one of the windows
+type Side struct {
+	Count  int
+	Errors int
+	values *sketch.Sketch
+}
+
+func (s *Side) Add(failed bool, value float64, ok bool) {
+	s.Count++
+
+	if failed {
+		s.Errors++
+	}
+
+	if !ok {
+		return
+	}
+
+	if s.values == nil {
+		s.values = sketch.Create(sketch.DEFAULT_ACCURACY)
+	}
+
+	s.values.Add(value)
+}
+
+func (s *Side) ErrorRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return float64(s.Errors) / float64(s.Count)
+}
+
+// the quantile of the values, false when there are none
+func (s *Side) Quantile(q float64) (float64, bool) {
+	if s.values == nil || s.values.Count() == 0 {
+		return 0, false
+	}
+
+	return s.values.Quantile(q), true
+}
+
+func (s *Side) valueCount() uint64 {
+	if s.values == nil {
+		return 0
+	}
+
+	return s.values.Count()
+}
+
+// Group is the same group, like a route, before and after
+type Group struct {
+	Before Side
+	After  Side
+}
+
+// how many standard errors apart two proportions are, x1 of n1 and x2 of n2
+func proportionScore(x1 int, n1 int, x2 int, n2 int) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 0
+	}
+
+	p1 := float64(x1) / float64(n1)
+	p2 := float64(x2) / float64(n2)
+	p := float64(x1+x2) / float64(n1+n2)
+	deviation := math.Sqrt(p * (1 - p) * (1/float64(n1) + 1/float64(n2)))
+
+	if deviation == 0 {
+		return 0
+	}
+
+	return (p2 - p1) / deviation
+}
+
+// the relative change of the share of the requests of the group. the
+// shares are compared, and not the counts, so windows of different
+// lengths can be compared
+func (g *Group) TrafficChange(totalBefore int, totalAfter int) float64 {
+	if g.Before.Count == 0 || totalBefore == 0 || totalAfter == 0 {
+		return 0
+	}
+
+	before := float64(g.Before.Count) / float64(totalBefore)
+	after := float64(g.After.Count) / float64(totalAfter)
+
+	return after/before - 1
+}
+
+func percentileChanged(before *Side, after *Side, q float64) bool {
+	if before.valueCount() < MIN_VALUES || after.valueCount() < MIN_VALUES {
+		return false
+	}
+
+	a, _ := before.Quantile(q)
+	b, _ := after.Quantile(q)
+
+	return math.Abs(b-a) >= MIN_PERCENTILE_CHANGE*max(a, 1)
+}
+
+// Changes tells what changed significantly in the group
+func (g *Group) Changes(totalBefore int, totalAfter int) []string {
+	if g.Before.Count == 0 {
+		return []string{NEW}
+	}
+
+	if g.After.Count == 0 {
+		return []string{GONE}
+	}
+
+	changes := make([]string, 0)
+
+	score := proportionScore(g.Before.Count, totalBefore, g.After.Count, totalAfter)
+
+	if math.Abs(score) >= SCORE && math.Abs(g.TrafficChange(totalBefore, totalAfter)) >= MIN_TRAFFIC_CHANGE {
+		changes = append(changes, TRAFFIC)
+	}
+
+	score = proportionScore(g.Before.Errors, g.Before.Count, g.After.Errors, g.After.Count)
+
+	if math.Abs(score) >= SCORE && math.Abs(g.After.ErrorRate()-g.Before.ErrorRate()) >= MIN_ERROR_CHANGE {
+		changes = append(changes, ERRORS)
+	}
+
+	if percentileChanged(&g.Before, &g.After, 0.5) {
+		changes = append(changes, P50)
+	}
+
+	if percentileChanged(&g.Before, &g.After, 0.95) {
+		changes = append(changes, P95)
+	}
+
+	return changes
+}
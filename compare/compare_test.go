@@ -0,0 +1,77 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func side(count int, errors int, value float64) Side {
+	s := Side{}
+
+	for i := range count {
+		s.Add(i < errors, value, true)
+	}
+
+	return s
+}
+
+func TestNewAndGone(t *testing.T) {
+	g := Group{After: side(10, 0, 1)}
+
+	assert.Equal(t, []string{NEW}, g.Changes(100, 100))
+
+	g = Group{Before: side(10, 0, 1)}
+
+	assert.Equal(t, []string{GONE}, g.Changes(100, 100))
+}
+
+func TestNoChanges(t *testing.T) {
+	g := Group{Before: side(100, 2, 1000), After: side(52, 1, 1010)}
+
+	// half the requests in a window half as long
+	assert.Empty(t, g.Changes(1000, 500))
+	assert.InDelta(t, 0.04, g.TrafficChange(1000, 500), 0.001)
+}
+
+func TestTraffic(t *testing.T) {
+	g := Group{Before: side(100, 0, 1000), After: side(400, 0, 1000)}
+
+	assert.Equal(t, []string{TRAFFIC}, g.Changes(1000, 1000))
+	assert.Equal(t, 3.0, g.TrafficChange(1000, 1000))
+}
+
+func TestSmallChangesAreNotTraffic(t *testing.T) {
+	// significant, but just 10% more
+	g := Group{Before: side(100000, 0, 1000), After: side(110000, 0, 1000)}
+
+	assert.Empty(t, g.Changes(1000000, 1000000))
+}
+
+func TestErrors(t *testing.T) {
+	g := Group{Before: side(1000, 5, 1000), After: side(1000, 80, 1000)}
+
+	assert.Equal(t, []string{ERRORS}, g.Changes(10000, 10000))
+	assert.Equal(t, 0.08, g.After.ErrorRate())
+}
+
+func TestPercentiles(t *testing.T) {
+	g := Group{Before: side(100, 0, 1000), After: side(100, 0, 3000)}
+
+	assert.Equal(t, []string{P50, P95}, g.Changes(1000, 1000))
+
+	p50, ok := g.After.Quantile(0.5)
+
+	assert.True(t, ok)
+	assert.InEpsilon(t, 3000, p50, 0.01)
+}
+
+func TestFewValuesAreNotCompared(t *testing.T) {
+	g := Group{Before: side(10, 0, 1000), After: side(10, 0, 3000)}
+
+	assert.Empty(t, g.Changes(1000, 1000))
+
+	_, ok := (&Side{}).Quantile(0.5)
+
+	assert.False(t, ok)
+}
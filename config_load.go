@@ -6,7 +6,17 @@ import (
 	"path"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/marcos-venicius/lfi/alert"
+	"github.com/marcos-venicius/lfi/geoip"
+	"github.com/marcos-venicius/lfi/metrics"
+	"github.com/marcos-venicius/lfi/openapi"
+	"github.com/marcos-venicius/lfi/route"
+	"github.com/marcos-venicius/lfi/slo"
+	"github.com/marcos-venicius/lfi/threat"
+	"github.com/marcos-venicius/lfi/useragent"
 )
 
 type order_t int
@@ -24,12 +34,34 @@ const (
 	ORDER_COUNT
 )
 
-const MAX_CONFIG_FILE_SIZE = 1024
+const MAX_CONFIG_FILE_SIZE = 64 * 1024
 
 type Configs struct {
-	regex  *regexp.Regexp
-	order  []order_t
-	format string
+	regex    *regexp.Regexp
+	order    []order_t
+	format   string
+	template string
+	routes   route.Matcher
+	spec     *openapi.Spec
+	agents   *useragent.Classifier
+	threats  *threat.Detector
+	geo      *geoip.Enricher
+	alerts   []alert.Rule
+	// where the alerts are written, stderr when empty
+	alertFile string
+	// the routes and statuses of failed logins, for -bruteforce. nil when
+	// the defaults should be used
+	loginRoutes   *route.Matcher
+	loginFailures []int
+	slos          []slo.Definition
+	metrics       []metrics.Definition
+
+	// the regex group of each order item
+	positions map[order_t]int
+	// named regex groups, like (?P<latency>\d+), are extra fields
+	fields map[string]int
+	// the threats of the logs are not detected, when nothing shows them
+	skipThreats bool
 }
 
 var configFileName = ".lfi"
@@ -146,9 +178,13 @@ func getFileSize(filePath string) (int64, error) {
 
 func LoadConfigs() (*Configs, error) {
 	configs := Configs{
-		regex:  defaultLogRegex,
-		order:  defaultOrder,
-		format: defaultFormatting,
+		regex:   defaultLogRegex,
+		order:   defaultOrder,
+		format:  defaultFormatting,
+		routes:  route.CreateMatcher(),
+		agents:  useragent.Default(),
+		threats: threat.Default(),
+		geo:     geoip.CreateEnricher(),
 	}
 
 	userHomeDir, err := os.UserHomeDir()
@@ -168,6 +204,10 @@ func LoadConfigs() (*Configs, error) {
 	if !exists {
 		configs.writeToConfigFile(configFilePath)
 
+		if err := configs.indexGroups(); err != nil {
+			return nil, err
+		}
+
 		return &configs, nil
 	}
 
@@ -187,6 +227,9 @@ func LoadConfigs() (*Configs, error) {
 		return nil, err
 	}
 
+	// alert_exec lines may come before the alert they refer to
+	alertCommands := make(map[string]string)
+
 	for number, line := range strings.Split(string(data), "\n") {
 		line = strings.TrimSpace(line)
 
@@ -225,14 +268,178 @@ func LoadConfigs() (*Configs, error) {
 			configs.order = order
 		case "format":
 			configs.format = value
+		case "template":
+			configs.template = value
+		case "openapi":
+			spec, err := openapi.Load(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
+
+			configs.spec = spec
+		case "ua_rules":
+			agents, err := useragent.Load(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
+
+			configs.agents = agents
+		case "threat_rules":
+			threats, err := threat.Load(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
+
+			configs.threats = threats
+		case "geoip":
+			reader, err := geoip.Open(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
+
+			configs.geo.Add(reader)
+		case "alert":
+			rule, err := alert.ParseRule(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
+
+			if _, err := compileQuery(rule.Query); err != nil {
+				return nil, fmt.Errorf("%s:%d error: invalid query of alert \"%s\": %s", configFilePath, number+1, rule.Name, err.Error())
+			}
+
+			for _, other := range configs.alerts {
+				if other.Name == rule.Name {
+					return nil, fmt.Errorf("%s:%d error: duplicated alert \"%s\"", configFilePath, number+1, rule.Name)
+				}
+			}
+
+			configs.alerts = append(configs.alerts, rule)
+		case "alert_exec":
+			name, command, found := strings.Cut(value, " ")
+
+			if !found || len(strings.TrimSpace(command)) == 0 {
+				return nil, fmt.Errorf("%s:%d error: expected the name of an alert and a command, like \"alert_exec = errors ./page.sh\"", configFilePath, number+1)
+			}
+
+			alertCommands[name] = strings.TrimSpace(command)
+		case "alert_file":
+			configs.alertFile = value
+		case "login_route":
+			if configs.loginRoutes == nil {
+				matcher := route.CreateMatcher()
+				configs.loginRoutes = &matcher
+			}
+
+			if err := configs.loginRoutes.Add(value); err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
+		case "login_failures":
+			failures, err := parseStatuses(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
+
+			configs.loginFailures = failures
+		case "slo":
+			definition, err := slo.ParseDefinition(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
+
+			configs.slos = append(configs.slos, definition)
+		case "metric":
+			definition, err := metrics.ParseDefinition(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
+
+			configs.metrics = append(configs.metrics, definition)
+		case "route":
+			if err := configs.routes.Add(value); err != nil {
+				return nil, fmt.Errorf("%s:%d error: %s", configFilePath, number+1, err.Error())
+			}
 		default:
 			return nil, fmt.Errorf("%s:%d error: invalid config key \"%s\"", configFilePath, number+1, key)
 		}
 	}
 
+	if err := configs.indexGroups(); err != nil {
+		return nil, fmt.Errorf("%s error: %s", configFilePath, err.Error())
+	}
+
+	for name, command := range alertCommands {
+		found := false
+
+		for i := range configs.alerts {
+			if configs.alerts[i].Name == name {
+				configs.alerts[i].Exec = command
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%s error: alert_exec of unknown alert \"%s\"", configFilePath, name)
+		}
+	}
+
+	for _, rule := range configs.alerts {
+		if len(rule.Per) > 0 && !isKnownField(rule.Per, &configs) {
+			return nil, fmt.Errorf("%s error: unknown field \"%s\" in the alert \"%s\"", configFilePath, rule.Per, rule.Name)
+		}
+	}
+
 	return &configs, nil
 }
 
+// the unnamed groups of the regex are the order items, in the same
+// order, and the named ones are extra fields
+func (c *Configs) indexGroups() error {
+	c.positions = make(map[order_t]int)
+	c.fields = make(map[string]int)
+
+	unnamed := 0
+
+	for i, name := range c.regex.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+
+		if len(name) == 0 {
+			if unnamed < len(c.order) {
+				c.positions[c.order[unnamed]] = i
+			}
+
+			unnamed++
+			continue
+		}
+
+		if _, ok := (log_t{}).values()[name]; ok {
+			return fmt.Errorf("the regex group \"%s\" has the same name of a builtin field", name)
+		}
+
+		if _, ok := (log_t{}).dynamicField(name); ok {
+			return fmt.Errorf("the regex group \"%s\" has the same name of a builtin field", name)
+		}
+
+		c.fields[name] = i
+	}
+
+	if unnamed != len(c.order) {
+		return fmt.Errorf("the regex has %d unnamed groups but the order has %d items", unnamed, len(c.order))
+	}
+
+	return nil
+}
+
 func parseOrderArray(configFilePath string, lineNumber int, content string) ([]order_t, error) {
 	order := make([]order_t, 0, ORDER_COUNT)
 
@@ -340,3 +547,20 @@ func parseOrderArray(configFilePath string, lineNumber int, content string) ([]o
 
 	return order, nil
 }
+
+// "401, 403" -> [401, 403]
+func parseStatuses(content string) ([]int, error) {
+	statuses := make([]int, 0)
+
+	for _, field := range strings.Split(content, ",") {
+		status, err := strconv.Atoi(strings.TrimSpace(field))
+
+		if err != nil || status < 100 || status > 999 {
+			return nil, fmt.Errorf("invalid status \"%s\". expected a list like \"401, 403\"", strings.TrimSpace(field))
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
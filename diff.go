@@ -0,0 +1,402 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marcos-venicius/lfi/compare"
+	"github.com/marcos-venicius/quang"
+)
+
+type diff_group_t struct {
+	// the values of the group by fields, in the same order
+	values []any
+	compare.Group
+	changes []string
+}
+
+// differ_t compares the groups of the logs before and after, for lfi diff
+type differ_t struct {
+	fields []string
+	// the numeric field of the percentiles
+	field string
+
+	errors         *quang.Quang
+	errorVariables []string
+
+	groups      map[string]*diff_group_t
+	totalBefore int
+	totalAfter  int
+}
+
+// the layouts accepted by -at. the times without an offset are local
+var diffTimeLayouts = []string{logTimeLayout, time.RFC3339, time.DateTime}
+
+func parseDiffTime(text string) (time.Time, error) {
+	for _, layout := range diffTimeLayouts {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time \"%s\". expected something like \"2024-10-10 13:00:00\" or \"10/Oct/2024:13:00:00 +0000\"", text)
+}
+
+// parses the flags, even the ones after the arguments, like in
+// "lfi diff a.log b.log -group-by route", and returns the arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	positional := make([]string, 0)
+
+	for {
+		flags.Parse(args)
+
+		args = flags.Args()
+
+		if len(args) == 0 {
+			return positional
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func (d *differ_t) add(log log_t, after bool) {
+	values := log.values()
+	keyValues := make([]any, len(d.fields))
+	keys := make([]string, len(d.fields))
+
+	for i, field := range d.fields {
+		keyValues[i] = log.field(field, values)
+		keys[i] = fmt.Sprint(keyValues[i])
+	}
+
+	key := strings.Join(keys, "\x00")
+	group, ok := d.groups[key]
+
+	if !ok {
+		group = &diff_group_t{values: keyValues}
+		d.groups[key] = group
+	}
+
+	log.bindQueryVariables(d.errors, d.errorVariables)
+
+	failed, _ := d.errors.Eval()
+	value, isNumber := numberOf(log.field(d.field, values))
+
+	if after {
+		d.totalAfter++
+		group.After.Add(failed, value, isNumber)
+	} else {
+		d.totalBefore++
+		group.Before.Add(failed, value, isNumber)
+	}
+}
+
+// the changed groups first, then the biggest ones
+func (d *differ_t) sorted(top int, onlyChanged bool) []*diff_group_t {
+	groups := make([]*diff_group_t, 0, len(d.groups))
+
+	for _, group := range d.groups {
+		group.changes = group.Changes(d.totalBefore, d.totalAfter)
+
+		if !onlyChanged || len(group.changes) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+
+		if (len(a.changes) > 0) != (len(b.changes) > 0) {
+			return len(a.changes) > 0
+		}
+
+		if a.Before.Count+a.After.Count != b.Before.Count+b.After.Count {
+			return a.Before.Count+a.After.Count > b.Before.Count+b.After.Count
+		}
+
+		return fmt.Sprint(a.values...) < fmt.Sprint(b.values...)
+	})
+
+	if top > 0 && len(groups) > top {
+		groups = groups[:top]
+	}
+
+	return groups
+}
+
+func (d *differ_t) columns() []string {
+	return []string{"before", "after", "change", "errors_before", "errors_after", "p50_before", "p50_after", "p95_before", "p95_after", "changes"}
+}
+
+func quantileResult(side *compare.Side, q float64) any {
+	if value, ok := side.Quantile(q); ok {
+		return roundResult(value)
+	}
+
+	return nil
+}
+
+// the results of the group, in the order of the columns
+func (d *differ_t) results(g *diff_group_t) []any {
+	var change any
+
+	if g.Before.Count > 0 && g.After.Count > 0 {
+		change = roundResult(g.TrafficChange(d.totalBefore, d.totalAfter) * 100)
+	}
+
+	return []any{
+		g.Before.Count,
+		g.After.Count,
+		change,
+		roundResult(g.Before.ErrorRate() * 100),
+		roundResult(g.After.ErrorRate() * 100),
+		quantileResult(&g.Before, 0.5),
+		quantileResult(&g.After, 0.5),
+		quantileResult(&g.Before, 0.95),
+		quantileResult(&g.After, 0.95),
+		strings.Join(g.changes, ","),
+	}
+}
+
+// +35%
+func displayChange(value any) string {
+	if value == nil {
+		return "-"
+	}
+
+	text := fmt.Sprintf("%v%%", value)
+
+	if !strings.HasPrefix(text, "-") {
+		text = "+" + text
+	}
+
+	return text
+}
+
+func (d *differ_t) write(w io.Writer, output string, top int, onlyChanged bool, colorize bool) error {
+	groups := d.sorted(top, onlyChanged)
+	columns := d.columns()
+
+	switch output {
+	case "json":
+		rows := make([]map[string]any, 0, len(groups))
+
+		for _, group := range groups {
+			row := make(map[string]any)
+
+			for i, field := range d.fields {
+				row[field] = group.values[i]
+			}
+
+			for i, result := range d.results(group) {
+				row[columns[i]] = result
+			}
+
+			row["changes"] = group.changes
+			rows = append(rows, row)
+		}
+
+		return json.NewEncoder(w).Encode(rows)
+	case "csv":
+		writer := csv.NewWriter(w)
+
+		writer.Write(append(append([]string{}, d.fields...), columns...))
+
+		for _, group := range groups {
+			row := make([]string, 0, len(d.fields)+len(columns))
+
+			for _, value := range append(group.values, d.results(group)...) {
+				row = append(row, displayResult(value))
+			}
+
+			writer.Write(row)
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, field := range d.fields {
+		fmt.Fprintf(writer, "%s\t", strings.ToUpper(field))
+	}
+
+	fmt.Fprintf(writer, "%s\n", strings.ToUpper(strings.Join(columns, "\t")))
+
+	for _, group := range groups {
+		for _, value := range group.values {
+			fmt.Fprintf(writer, "%s\t", displayResult(value))
+		}
+
+		results := d.results(group)
+
+		fmt.Fprintf(writer, "%d\t%d\t%s\t%v%%\t%v%%\t", results[0], results[1], displayChange(results[2]), results[3], results[4])
+
+		for _, value := range results[5:9] {
+			fmt.Fprintf(writer, "%s\t", displayResult(value))
+		}
+
+		changes := strings.Join(group.changes, ",")
+
+		// the last column, so the colors don't break the alignment
+		if colorize && len(changes) > 0 {
+			changes = highlightStart + changes + highlightEnd
+		}
+
+		fmt.Fprintf(writer, "%s\n", changes)
+	}
+
+	return writer.Flush()
+}
+
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of lfi diff: lfi diff [flags] before.log after.log, or lfi diff -at time [flags] [file]\n\ncompares the logs before and after, like before and after a deploy, showing what changed in each group\n\n")
+		flags.PrintDefaults()
+	}
+
+	common := addLogFlags(flags)
+	groupBy := flags.String("group-by", "route", "compare the logs by a comma separated list of fields, like \"route,method\"")
+	errors := flags.String("errors", "status gte 500", "the quang filter of the logs counted as errors")
+	field := flags.String("field", "size", "the numeric field of the percentiles, like a latency captured by the config regex")
+	at := flags.String("at", "", "split a single file, or stdin, at this time, like \"2024-10-10 13:00:00\", in the local time zone, or \"10/Oct/2024:13:00:00 +0000\", instead of comparing two files")
+	top := flags.Int("top", 0, "only show the first n groups")
+	output := flags.String("output", "table", "one of table, json or csv")
+	onlyChanged := flags.Bool("changed", false, "only show the groups that changed: new, gone, or with a significant change of traffic, errors or percentiles")
+	color := flags.String("color", "auto", "highlight the changes. one of auto, always or never")
+
+	files := parseInterspersed(flags, args)
+
+	if (len(*at) == 0 && len(files) != 2) || (len(*at) > 0 && len(files) > 1) {
+		flags.Usage()
+		return 1
+	}
+
+	configs, q, dynamicVariables, err := common.load()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	fields, err := parseFieldList(*groupBy, configs)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: -group-by: %s\n", err.Error())
+		return 1
+	}
+
+	if !isKnownField(*field, configs) {
+		fmt.Fprintf(os.Stderr, "error: -field: unknown field \"%s\"\n", *field)
+		return 1
+	}
+
+	errorQuery, err := compileQuery(*errors)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: -errors: %s\n", err.Error())
+		return 1
+	}
+
+	if err := validateOutputFormat(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "error: -output: %s\n", err.Error())
+		return 1
+	}
+
+	colorize, err := shouldColorize(*color)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: -color: %s\n", err.Error())
+		return 1
+	}
+
+	d := &differ_t{
+		fields:         fields,
+		field:          *field,
+		errors:         errorQuery,
+		errorVariables: dynamicQueryVariables(*errors),
+		groups:         make(map[string]*diff_group_t),
+	}
+
+	read := func(path string, fn func(log log_t, t time.Time)) error {
+		if len(path) == 0 {
+			readMatchingLogs(os.Stdin, *common.breakParamsOut, configs, q, dynamicVariables, fn)
+			return nil
+		}
+
+		file, err := os.Open(path)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		readMatchingLogs(file, *common.breakParamsOut, configs, q, dynamicVariables, fn)
+
+		return nil
+	}
+
+	if len(*at) > 0 {
+		split, err := parseDiffTime(*at)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -at: %s\n", err.Error())
+			return 1
+		}
+
+		path := ""
+
+		if len(files) == 1 {
+			path = files[0]
+		}
+
+		err = read(path, func(log log_t, t time.Time) {
+			if !t.IsZero() {
+				d.add(log, !t.Before(split))
+			}
+		})
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			return 1
+		}
+	} else {
+		for i, path := range files {
+			err := read(path, func(log log_t, t time.Time) {
+				d.add(log, i == 1)
+			})
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+				return 1
+			}
+		}
+	}
+
+	if d.totalBefore == 0 || d.totalAfter == 0 {
+		fmt.Fprintln(os.Stderr, "error: nothing to compare, there are no logs before or after")
+		return 1
+	}
+
+	if err := d.write(os.Stdout, *output, *top, *onlyChanged, colorize && *output == "table"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiffTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("", -3*60*60)
+	defer func() { time.Local = local }()
+
+	cases := map[string]time.Time{
+		"2024-10-10 13:00:00":        time.Date(2024, 10, 10, 16, 0, 0, 0, time.UTC),
+		"10/Oct/2024:13:00:00 +0000": time.Date(2024, 10, 10, 13, 0, 0, 0, time.UTC),
+		"2024-10-10T13:00:00+02:00":  time.Date(2024, 10, 10, 11, 0, 0, 0, time.UTC),
+	}
+
+	for text, expected := range cases {
+		at, err := parseDiffTime(text)
+
+		assert.Nil(t, err, text)
+		assert.True(t, expected.Equal(at), text)
+	}
+
+	_, err := parseDiffTime("yesterday")
+
+	assert.NotNil(t, err)
+}
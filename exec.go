@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// commands waiting for a free slot, after it the logs wait too
+const execQueueSize = 1000
+
+var placeholderRegex = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
+type job_t struct {
+	// how the user wrote it, and what sh runs
+	command string
+	script  string
+	// the $1, $2... of the script
+	args []string
+	// the lines of a batch, empty for the commands of a single log
+	stdin string
+	size  int
+}
+
+// executor_t runs commands for the matching logs, like -exec 'ban-ip {ip}'
+type executor_t struct {
+	command string
+	// the command with the placeholders replaced by arguments
+	script       string
+	fields       []string
+	batchCommand string
+	batchSize    int
+	timeout      time.Duration
+
+	tokens   []string
+	template *template.Template
+
+	jobs    chan job_t
+	workers sync.WaitGroup
+	batch   []string
+	stop    chan struct{}
+	flushed chan struct{}
+	mutex   sync.Mutex
+}
+
+// the {field} placeholders should be known fields, so typos are found before the first log
+func validatePlaceholders(command string, configs *Configs) error {
+	for _, matches := range placeholderRegex.FindAllStringSubmatch(command, -1) {
+		if !isKnownField(matches[1], configs) {
+			return fmt.Errorf("unknown field \"%s\" in \"%s\"", matches[1], command)
+		}
+	}
+
+	return nil
+}
+
+// "ban-ip {ip}" -> `ban-ip "${1}"`, and the fields of the arguments. the
+// values are passed to sh as arguments, so they are never parsed by the
+// shell and a log can't change the command, like a user agent with "; rm -rf"
+func expandPlaceholders(command string) (string, []string) {
+	fields := make([]string, 0)
+	positions := make(map[string]int)
+
+	script := placeholderRegex.ReplaceAllStringFunc(command, func(placeholder string) string {
+		field := placeholder[1 : len(placeholder)-1]
+		position, ok := positions[field]
+
+		if !ok {
+			fields = append(fields, field)
+			position = len(fields)
+			positions[field] = position
+		}
+
+		return fmt.Sprintf(`"${%d}"`, position)
+	})
+
+	return script, fields
+}
+
+// the values of the fields of the placeholders of a log
+func placeholderArgs(fields []string, log log_t) []string {
+	values := log.values()
+	args := make([]string, len(fields))
+
+	for i, field := range fields {
+		args[i] = fmt.Sprint(log.field(field, values))
+	}
+
+	return args
+}
+
+func createExecutor(command string, batchCommand string, batchSize int, batchInterval time.Duration, concurrency int, timeout time.Duration, tokens []string, t *template.Template) *executor_t {
+	script, fields := expandPlaceholders(command)
+
+	e := &executor_t{
+		command:      command,
+		script:       script,
+		fields:       fields,
+		batchCommand: batchCommand,
+		batchSize:    batchSize,
+		timeout:      timeout,
+		tokens:       tokens,
+		template:     t,
+		jobs:         make(chan job_t, execQueueSize),
+		stop:         make(chan struct{}),
+		flushed:      make(chan struct{}),
+	}
+
+	for range concurrency {
+		e.workers.Add(1)
+
+		go e.work()
+	}
+
+	if len(batchCommand) > 0 {
+		go e.flushPeriodically(batchInterval)
+	} else {
+		close(e.flushed)
+	}
+
+	return e
+}
+
+func (e *executor_t) add(log log_t) {
+	if len(e.command) > 0 {
+		e.jobs <- job_t{command: e.command, script: e.script, args: placeholderArgs(e.fields, log)}
+	}
+
+	if len(e.batchCommand) == 0 {
+		return
+	}
+
+	line := formatLine(e.tokens, e.template, log)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.batch = append(e.batch, line)
+
+	if len(e.batch) >= e.batchSize {
+		e.flush()
+	}
+}
+
+// queues the batch command, the mutex should be locked
+func (e *executor_t) flush() {
+	if len(e.batch) == 0 {
+		return
+	}
+
+	e.jobs <- job_t{command: e.batchCommand, script: e.batchCommand, stdin: strings.Join(e.batch, "\n") + "\n", size: len(e.batch)}
+
+	e.batch = nil
+}
+
+func (e *executor_t) flushPeriodically(interval time.Duration) {
+	defer close(e.flushed)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			e.mutex.Lock()
+			e.flush()
+			e.mutex.Unlock()
+
+			return
+		case <-ticker.C:
+			e.mutex.Lock()
+			e.flush()
+			e.mutex.Unlock()
+		}
+	}
+}
+
+func (e *executor_t) work() {
+	defer e.workers.Done()
+
+	for job := range e.jobs {
+		if err := e.run(job); err != nil {
+			fmt.Fprintf(os.Stderr, "error: exec \"%s\": %s\n", job.command, err.Error())
+		}
+	}
+}
+
+func (e *executor_t) run(job job_t) error {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	// the name of the script, $0, is lfi
+	command := exec.CommandContext(ctx, "sh", append([]string{"-c", job.script, "lfi"}, job.args...)...)
+
+	command.Stdout = os.Stderr
+	command.Stderr = os.Stderr
+	// children that keep the output open don't hold the worker forever
+	command.WaitDelay = time.Second
+
+	killProcessGroup(command)
+
+	if job.size > 0 {
+		command.Stdin = strings.NewReader(job.stdin)
+		command.Env = append(os.Environ(), fmt.Sprintf("LFI_BATCH_SIZE=%d", job.size))
+	}
+
+	err := command.Run()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("killed after %s", e.timeout)
+	}
+
+	return err
+}
+
+// runs the last batch and waits for every command
+func (e *executor_t) wait() {
+	close(e.stop)
+
+	<-e.flushed
+
+	close(e.jobs)
+
+	e.workers.Wait()
+}
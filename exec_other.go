@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// only the command itself is killed by a timeout
+func killProcessGroup(command *exec.Cmd) {}
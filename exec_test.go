@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	cases := []struct {
+		command string
+		script  string
+		fields  []string
+	}{
+		{"ban-ip {ip}", `ban-ip "${1}"`, []string{"ip"}},
+		{"notify {ip} {route} {ip}", `notify "${1}" "${2}" "${1}"`, []string{"ip", "route"}},
+		{"echo '{param_id}'", `echo '"${1}"'`, []string{"param_id"}},
+		{"echo {} {not a field}", "echo {} {not a field}", []string{}},
+	}
+
+	for _, c := range cases {
+		script, fields := expandPlaceholders(c.command)
+
+		assert.Equal(t, c.script, script, c.command)
+		assert.Equal(t, c.fields, fields, c.command)
+	}
+}
+
+// the contents of the file, or "" while it doesn't exist
+func contents(path string) string {
+	content, _ := os.ReadFile(path)
+
+	return string(content)
+}
+
+func TestExecArguments(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	pwned := filepath.Join(dir, "pwned")
+
+	e := createExecutor(`printf '%s|%s' {agent} "{ip}" > `+output, "", 0, time.Hour, 1, time.Second, nil, nil)
+
+	e.add(log_t{ip: "10.0.0.1", userAgent: `x'; touch ` + pwned + `; echo "$(touch ` + pwned + `)`})
+	e.wait()
+
+	assert.Equal(t, `x'; touch `+pwned+`; echo "$(touch `+pwned+`)|10.0.0.1`, contents(output))
+	assert.NoFileExists(t, pwned)
+}
+
+func TestExecTimeout(t *testing.T) {
+	e := createExecutor("sleep 5", "", 0, time.Hour, 1, 50*time.Millisecond, nil, nil)
+	defer e.wait()
+
+	started := time.Now()
+	err := e.run(job_t{command: "sleep 5", script: "sleep 5"})
+
+	assert.Equal(t, "killed after 50ms", err.Error())
+	assert.Less(t, time.Since(started), 2*time.Second)
+}
+
+func TestExecBatches(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	sizes := filepath.Join(dir, "sizes")
+
+	e := createExecutor("", `echo "$LFI_BATCH_SIZE" >> `+sizes+`; cat >> `+output, 2, time.Hour, 1, time.Second, []string{"%ip"}, nil)
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		e.add(log_t{ip: ip})
+	}
+
+	// the last batch is not full, wait runs it
+	e.wait()
+
+	assert.Equal(t, "10.0.0.1\n10.0.0.2\n10.0.0.3\n", contents(output))
+	assert.Equal(t, "2\n1\n", contents(sizes))
+}
+
+func TestExecBatchInterval(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "output")
+
+	e := createExecutor("", "cat >> "+output, 100, 10*time.Millisecond, 1, time.Second, []string{"%ip"}, nil)
+	defer e.wait()
+
+	e.add(log_t{ip: "10.0.0.1"})
+
+	assert.Eventually(t, func() bool { return contents(output) == "10.0.0.1\n" }, time.Second, 5*time.Millisecond)
+}
+
+func TestExecWait(t *testing.T) {
+	dir := t.TempDir()
+
+	e := createExecutor("sleep 0.1; touch "+dir+"/{ip}", "", 0, time.Hour, 2, time.Second, nil, nil)
+
+	for _, ip := range []string{"a", "b", "c"} {
+		e.add(log_t{ip: ip})
+	}
+
+	e.wait()
+
+	files, _ := os.ReadDir(dir)
+	names := make([]string, 0, len(files))
+
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+
+	assert.Equal(t, "a b c", strings.Join(names, " "))
+}
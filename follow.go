@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+const followPollInterval = 250 * time.Millisecond
+
+// follower_t reads a file like "tail -f", waiting for new lines instead
+// of returning io.EOF
+type follower_t struct {
+	file   *os.File
+	offset int64
+}
+
+// starts at the end of the file, only the new lines are read
+func followFile(path string) (*follower_t, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := file.Seek(0, io.SeekEnd)
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &follower_t{file: file, offset: offset}, nil
+}
+
+func (f *follower_t) Read(bytes []byte) (int, error) {
+	for {
+		n, err := f.file.Read(bytes)
+
+		f.offset += int64(n)
+
+		if n > 0 {
+			return n, nil
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		// the file was truncated, like when logs are rotated with copytruncate
+		if stat, err := f.file.Stat(); err == nil && stat.Size() < f.offset {
+			f.offset, _ = f.file.Seek(0, io.SeekStart)
+		}
+
+		time.Sleep(followPollInterval)
+	}
+}
+
+func (f *follower_t) Close() error {
+	return f.file.Close()
+}
@@ -3,21 +3,58 @@ package formatter
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type Formatter struct {
-	labels map[string]struct{}
+	labels   map[string]struct{}
+	prefixes []string
 }
 
 func isAlpha(c byte) bool {
 	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
 }
 
+func isLabelChar(c byte) bool {
+	return isAlpha(c) || (c >= '0' && c <= '9') || c == '_'
+}
+
+func (f Formatter) isValidLabel(label string) bool {
+	if _, ok := f.labels[label]; ok {
+		return true
+	}
+
+	for _, prefix := range f.prefixes {
+		if rest, ok := strings.CutPrefix(label, prefix); ok && isName(rest) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// letters and underscores, like the names of quang variables
+func isName(text string) bool {
+	if len(text) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(text); i++ {
+		if !isAlpha(text[i]) && text[i] != '_' {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (f Formatter) parseLabel(format string, index int) (string, int, error) {
 	j := index
 
 	for i := index + 1; i < len(format); i += 1 {
-		if !isAlpha(format[i]) {
+		if !isLabelChar(format[i]) {
 			break
 		}
 
@@ -30,7 +67,7 @@ func (f Formatter) parseLabel(format string, index int) (string, int, error) {
 
 	label := format[index+1 : j+1]
 
-	if _, ok := f.labels[label]; !ok {
+	if !f.isValidLabel(label) {
 		return "", 0, errors.New(fmt.Sprintf("invalid label %%%s", label))
 	}
 
@@ -51,6 +88,73 @@ func parseSpaces(format string, index int) (string, int) {
 	return format[index : j+1], j + 1
 }
 
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func parseHexEscape(format string, index int, digits int) (string, int, error) {
+	start := index + 2
+
+	for i := start; i < start+digits; i += 1 {
+		if i >= len(format) {
+			return "", 0, errors.New(fmt.Sprintf("format has an incomplete scape sequence at position %d", index+1))
+		}
+
+		if !isHexDigit(format[i]) {
+			return "", 0, errors.New(fmt.Sprintf("format has an invalid hex digit \"%c\" at position %d", format[i], i+1))
+		}
+	}
+
+	code, _ := strconv.ParseUint(format[start:start+digits], 16, 32)
+
+	if digits == 2 {
+		return string([]byte{byte(code)}), start + digits, nil
+	}
+
+	if !utf8.ValidRune(rune(code)) {
+		return "", 0, errors.New(fmt.Sprintf("format has an invalid unicode code point at position %d", index+1))
+	}
+
+	return string(rune(code)), start + digits, nil
+}
+
+// decodes the escape sequence starting at format[index] (the backslash).
+// the single quote can only be escaped inside strings.
+func parseEscape(format string, index int, quoted bool) (string, int, error) {
+	if index >= len(format)-1 {
+		return "", 0, errors.New(fmt.Sprintf("format has an invalid scape sequence at position %d", index+1))
+	}
+
+	switch format[index+1] {
+	case 'n':
+		return "\n", index + 2, nil
+	case 't':
+		return "\t", index + 2, nil
+	case 'r':
+		return "\r", index + 2, nil
+	case '0':
+		return "\x00", index + 2, nil
+	case 'e':
+		return "\x1b", index + 2, nil
+	case '\\':
+		return "\\", index + 2, nil
+	case '%':
+		return "%", index + 2, nil
+	case '\'':
+		if quoted {
+			return "'", index + 2, nil
+		}
+	case 'x':
+		return parseHexEscape(format, index, 2)
+	case 'u':
+		return parseHexEscape(format, index, 4)
+	case 'U':
+		return parseHexEscape(format, index, 8)
+	}
+
+	return "", 0, errors.New(fmt.Sprintf("format has an invalid scape sequence at position %d", index+1))
+}
+
 func parseString(format string, index int) (string, int, error) {
 	end := index
 
@@ -58,17 +162,14 @@ func parseString(format string, index int) (string, int, error) {
 		end = i
 
 		if format[i] == '\\' {
-			if i >= len(format)-1 {
-				return "", 0, errors.New(fmt.Sprintf("format has an invalid scape sequence at position %d", i+1))
-			}
+			_, nextIndex, err := parseEscape(format, i, true)
 
-			switch format[i+1] {
-			case '\'':
-				i += 1
-				continue
-			default:
-				return "", 0, errors.New(fmt.Sprintf("format has an invalid scape sequence at position %d", i+1))
+			if err != nil {
+				return "", 0, err
 			}
+
+			i = nextIndex - 1
+			continue
 		}
 
 		if format[i] == '\'' {
@@ -88,18 +189,43 @@ func parseString(format string, index int) (string, int, error) {
 }
 
 func parseScapeSequence(format string, index int) (string, int, error) {
-	if index >= len(format)-1 {
-		return "", 0, errors.New(fmt.Sprintf("format has an invalid scape sequence at position %d", index+1))
+	return parseEscape(format, index, false)
+}
+
+// any non ascii character outside strings is kept as it is, so things like
+// arrows can be used without quoting them.
+func parseUnicode(format string, index int) (string, int, error) {
+	r, size := utf8.DecodeRuneInString(format[index:])
+
+	// a literal U+FFFD is valid, only a single invalid byte is an error
+	if r == utf8.RuneError && size == 1 {
+		return "", 0, errors.New(fmt.Sprintf("format has an invalid utf-8 sequence at position %d", index+1))
 	}
 
-	switch format[index+1] {
-	case 'n':
-		return "\n", index + 2, nil
-	case 't':
-		return "\t", index + 2, nil
+	return string(r), index + size, nil
+}
+
+// Unquote returns the text of a string token produced by ParseFormatString
+// without the surrounding quotes and with its escape sequences decoded.
+func Unquote(token string) string {
+	var builder strings.Builder
+
+	for i := 1; i < len(token)-1; {
+		if token[i] == '\\' {
+			text, nextIndex, err := parseEscape(token, i, true)
+
+			if err == nil {
+				builder.WriteString(text)
+				i = nextIndex
+				continue
+			}
+		}
+
+		builder.WriteByte(token[i])
+		i += 1
 	}
 
-	return "", 0, errors.New(fmt.Sprintf("format has an invalid scape sequence at position %d", index+1))
+	return builder.String()
 }
 
 func (f Formatter) ParseFormatString(format string) ([]string, error) {
@@ -145,6 +271,18 @@ func (f Formatter) ParseFormatString(format string) ([]string, error) {
 			tokens = append(tokens, text)
 			index = nextIndex
 		default:
+			if format[index] >= utf8.RuneSelf {
+				text, nextIndex, err := parseUnicode(format, index)
+
+				if err != nil {
+					return nil, err
+				}
+
+				tokens = append(tokens, text)
+				index = nextIndex
+				continue
+			}
+
 			return nil, errors.New(fmt.Sprintf("unrecognized char: \"%c\" at position %d", format[index], index+1))
 		}
 	}
@@ -163,3 +301,12 @@ func CreateFormatter(labels []string) Formatter {
 		labels: l,
 	}
 }
+
+// AddLabelPrefix accepts any label starting with prefix and followed by
+// letters and underscores, which is useful for labels that are only known
+// while reading the logs, like "param_<name>".
+func (f *Formatter) AddLabelPrefix(prefix string) *Formatter {
+	f.prefixes = append(f.prefixes, prefix)
+
+	return f
+}
@@ -150,3 +150,117 @@ func TestParseScapeSequence(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "\t", text)
 }
+
+func TestParseScapeSequenceExtended(t *testing.T) {
+	cases := map[string]string{
+		"\\r":         "\r",
+		"\\0":         "\x00",
+		"\\e":         "\x1b",
+		"\\\\":        "\\",
+		"\\%":         "%",
+		"\\x41":       "A",
+		"\\u2192":     "→",
+		"\\U0001F600": "😀",
+	}
+
+	for str, expected := range cases {
+		text, nextIndex, err := parseScapeSequence(str, 0)
+
+		assert.Nil(t, err, str)
+		assert.Equal(t, expected, text, str)
+		assert.Equal(t, len(str), nextIndex, str)
+	}
+}
+
+func TestParseScapeSequenceErrors(t *testing.T) {
+	str := "ab\\x4"
+
+	_, _, err := parseScapeSequence(str, strings.Index(str, "\\"))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "format has an incomplete scape sequence at position 3", err.Error())
+
+	str = "ab\\u20g2"
+
+	_, _, err = parseScapeSequence(str, strings.Index(str, "\\"))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "format has an invalid hex digit \"g\" at position 7", err.Error())
+
+	str = "ab\\UFFFFFFFF"
+
+	_, _, err = parseScapeSequence(str, strings.Index(str, "\\"))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "format has an invalid unicode code point at position 3", err.Error())
+
+	str = "ab\\'"
+
+	_, _, err = parseScapeSequence(str, strings.Index(str, "\\"))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "format has an invalid scape sequence at position 3", err.Error())
+}
+
+func TestParseStringExtendedScapeSequences(t *testing.T) {
+	str := "'a\\\\b \\e[1m \\u2192 \\''"
+
+	text, nextIndex, err := parseString(str, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, str, text)
+	assert.Equal(t, len(str), nextIndex)
+	assert.Equal(t, "a\\b \x1b[1m → '", Unquote(text))
+
+	str = "'testing \\xZZ'"
+
+	_, _, err = parseString(str, 0)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "format has an invalid hex digit \"Z\" at position 12", err.Error())
+}
+
+func TestParseFormatStringUnicode(t *testing.T) {
+	var fmt = CreateFormatter([]string{"ip", "resource"})
+
+	tokens, err := fmt.ParseFormatString("%ip → %resource'\\e[0m'\\n")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"%ip", " ", "→", " ", "%resource", "'\\e[0m'", "\n"}, tokens)
+	assert.Equal(t, "\x1b[0m", Unquote(tokens[5]))
+
+	tokens, err = fmt.ParseFormatString("%ip \ufffd %resource")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"%ip", " ", "\ufffd", " ", "%resource"}, tokens)
+
+	_, err = fmt.ParseFormatString("%ip \xff")
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "format has an invalid utf-8 sequence at position 5", err.Error())
+}
+
+func TestLabelPrefix(t *testing.T) {
+	var fmt = CreateFormatter([]string{"path"})
+
+	fmt.AddLabelPrefix("param_")
+
+	tokens, err := fmt.ParseFormatString("%path %param_user_id")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"%path", " ", "%param_user_id"}, tokens)
+
+	_, err = fmt.ParseFormatString("%param_")
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid label %param_", err.Error())
+
+	_, err = fmt.ParseFormatString("%param_v2")
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid label %param_v2", err.Error())
+
+	_, err = fmt.ParseFormatString("%parameter")
+
+	assert.NotNil(t, err)
+}
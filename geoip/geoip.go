@@ -0,0 +1,100 @@
+package geoip
+
+import (
+	"net"
+
+	"github.com/marcos-venicius/lfi/lru"
+)
+
+// how many ip addresses are remembered by the enricher
+const CACHE_SIZE = 4096
+
+type Location struct {
+	// iso code of the country, like "BR"
+	Country string
+	// english name of the city
+	City  string
+	ASN   uint
+	ASOrg string
+}
+
+// Enricher looks up ip addresses in one or more databases, like a
+// GeoLite2-City and a GeoLite2-ASN, merging what each one knows.
+type Enricher struct {
+	readers []*Reader
+	cache   *lru.Cache[string, Location]
+}
+
+func CreateEnricher() *Enricher {
+	return &Enricher{
+		readers: make([]*Reader, 0),
+		cache:   lru.Create[string, Location](CACHE_SIZE),
+	}
+}
+
+func (e *Enricher) Add(reader *Reader) *Enricher {
+	e.readers = append(e.readers, reader)
+
+	return e
+}
+
+func (e *Enricher) Empty() bool {
+	return len(e.readers) == 0
+}
+
+// follows a path of map keys, like "country", "iso_code"
+func lookupPath(value any, keys ...string) any {
+	for _, key := range keys {
+		fields, ok := value.(map[string]any)
+
+		if !ok {
+			return nil
+		}
+
+		value = fields[key]
+	}
+
+	return value
+}
+
+func (l *Location) merge(record any) {
+	if country, ok := lookupPath(record, "country", "iso_code").(string); ok && len(l.Country) == 0 {
+		l.Country = country
+	}
+
+	if city, ok := lookupPath(record, "city", "names", "en").(string); ok && len(l.City) == 0 {
+		l.City = city
+	}
+
+	if asn := toUint(lookupPath(record, "autonomous_system_number")); asn != 0 && l.ASN == 0 {
+		l.ASN = asn
+	}
+
+	if org, ok := lookupPath(record, "autonomous_system_organization").(string); ok && len(l.ASOrg) == 0 {
+		l.ASOrg = org
+	}
+}
+
+// Lookup the location of the ip. unknown or invalid addresses have an empty location.
+func (e *Enricher) Lookup(ip string) Location {
+	if location, ok := e.cache.Get(ip); ok {
+		return location
+	}
+
+	location := Location{}
+	address := net.ParseIP(ip)
+
+	if address != nil {
+		for _, reader := range e.readers {
+			record, err := reader.Lookup(address)
+
+			if err == nil && record != nil {
+				location.merge(record)
+			}
+		}
+	}
+
+	e.cache.Add(ip, location)
+
+	return location
+}
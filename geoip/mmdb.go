@@ -0,0 +1,431 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+// https://maxmind.github.io/MaxMind-DB/
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const dataSectionSeparatorSize = 16
+
+const (
+	type_extended = iota
+	type_pointer
+	type_string
+	type_double
+	type_bytes
+	type_uint16
+	type_uint32
+	type_map
+	type_int32
+	type_uint64
+	type_uint128
+	type_array
+	type_container
+	type_end_marker
+	type_boolean
+	type_float
+)
+
+type Metadata struct {
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	DatabaseType string
+}
+
+// Reader of MaxMind DB (.mmdb) files, like the GeoLite2 databases
+type Reader struct {
+	buffer   []byte
+	tree     []byte
+	data     []byte
+	Metadata Metadata
+
+	ipv4Start uint
+}
+
+type decoder_t struct {
+	buffer []byte
+}
+
+func Open(filePath string) (*Reader, error) {
+	content, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := FromBytes(content)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err.Error())
+	}
+
+	return reader, nil
+}
+
+func toUint(value any) uint {
+	switch v := value.(type) {
+	case uint64:
+		return uint(v)
+	case uint32:
+		return uint(v)
+	case uint16:
+		return uint(v)
+	case int32:
+		return uint(v)
+	}
+
+	return 0
+}
+
+func FromBytes(buffer []byte) (*Reader, error) {
+	start := bytes.LastIndex(buffer, metadataMarker)
+
+	if start == -1 {
+		return nil, errors.New("invalid mmdb file, metadata not found")
+	}
+
+	metadataStart := start + len(metadataMarker)
+	metadata := decoder_t{buffer: buffer[metadataStart:]}
+
+	value, _, err := metadata.decode(0)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %s", err.Error())
+	}
+
+	fields, ok := value.(map[string]any)
+
+	if !ok {
+		return nil, errors.New("invalid metadata: expected a map")
+	}
+
+	reader := Reader{buffer: buffer}
+	reader.Metadata.NodeCount = toUint(fields["node_count"])
+	reader.Metadata.RecordSize = toUint(fields["record_size"])
+	reader.Metadata.IPVersion = toUint(fields["ip_version"])
+	reader.Metadata.DatabaseType, _ = fields["database_type"].(string)
+
+	switch reader.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", reader.Metadata.RecordSize)
+	}
+
+	treeSize := reader.Metadata.RecordSize * 2 / 8 * reader.Metadata.NodeCount
+
+	if treeSize+dataSectionSeparatorSize > uint(start) {
+		return nil, errors.New("invalid mmdb file, the search tree is bigger than the file")
+	}
+
+	reader.tree = buffer[:treeSize]
+	reader.data = buffer[treeSize+dataSectionSeparatorSize : start]
+
+	// ipv4 addresses live under ::/96 on ipv6 databases
+	if reader.Metadata.IPVersion == 6 {
+		node := uint(0)
+
+		for i := 0; i < 96 && node < reader.Metadata.NodeCount; i++ {
+			node = reader.record(node, 0)
+		}
+
+		reader.ipv4Start = node
+	}
+
+	return &reader, nil
+}
+
+func (r *Reader) record(node uint, bit uint) uint {
+	switch r.Metadata.RecordSize {
+	case 24:
+		offset := node*6 + bit*3
+		b := r.tree[offset : offset+3]
+
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		offset := node * 7
+		b := r.tree[offset : offset+7]
+
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	}
+
+	offset := node*8 + bit*4
+
+	return uint(binary.BigEndian.Uint32(r.tree[offset : offset+4]))
+}
+
+// Lookup the record of the ip address. it returns nil when the address
+// is not in the database.
+func (r *Reader) Lookup(ip net.IP) (any, error) {
+	address := ip.To4()
+	node := uint(0)
+
+	if address == nil {
+		if r.Metadata.IPVersion == 4 {
+			return nil, errors.New("cannot look up an ipv6 address in an ipv4 database")
+		}
+
+		address = ip.To16()
+
+		if address == nil {
+			return nil, errors.New("invalid ip address")
+		}
+	} else if r.Metadata.IPVersion == 6 {
+		node = r.ipv4Start
+	}
+
+	nodeCount := r.Metadata.NodeCount
+
+	for i := 0; i < len(address)*8 && node < nodeCount; i++ {
+		bit := uint(address[i/8]>>(7-uint(i%8))) & 1
+
+		node = r.record(node, bit)
+	}
+
+	if node == nodeCount {
+		return nil, nil
+	}
+
+	if node < nodeCount {
+		return nil, errors.New("invalid mmdb file, the search tree has no end")
+	}
+
+	offset := node - nodeCount - dataSectionSeparatorSize
+
+	if offset >= uint(len(r.data)) {
+		return nil, errors.New("invalid mmdb file, record pointing outside the data section")
+	}
+
+	decoder := decoder_t{buffer: r.data}
+
+	value, _, err := decoder.decode(offset)
+
+	return value, err
+}
+
+func (d decoder_t) bytes(offset uint, size uint) ([]byte, error) {
+	if offset+size > uint(len(d.buffer)) {
+		return nil, errors.New("unexpected end of data")
+	}
+
+	return d.buffer[offset : offset+size], nil
+}
+
+func bytesToUint(b []byte) uint64 {
+	var n uint64
+
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+
+	return n
+}
+
+// reads the control byte(s) at offset returning the type, the size and
+// the offset of the payload
+func (d decoder_t) control(offset uint) (int, uint, uint, error) {
+	b, err := d.bytes(offset, 1)
+
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	ctrl := b[0]
+	offset++
+
+	kind := int(ctrl >> 5)
+
+	if kind == type_extended {
+		b, err := d.bytes(offset, 1)
+
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		kind = 7 + int(b[0])
+		offset++
+	}
+
+	if kind == type_pointer {
+		return kind, uint(ctrl), offset, nil
+	}
+
+	size := uint(ctrl & 0x1f)
+
+	switch size {
+	case 29, 30, 31:
+		extra := size - 28
+
+		b, err := d.bytes(offset, extra)
+
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		switch size {
+		case 29:
+			size = 29 + uint(bytesToUint(b))
+		case 30:
+			size = 285 + uint(bytesToUint(b))
+		case 31:
+			size = 65821 + uint(bytesToUint(b))
+		}
+
+		offset += extra
+	}
+
+	return kind, size, offset, nil
+}
+
+// decode the value at offset returning it and the offset right after it
+func (d decoder_t) decode(offset uint) (any, uint, error) {
+	kind, size, offset, err := d.control(offset)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch kind {
+	case type_pointer:
+		ctrl := byte(size)
+		length := uint(ctrl>>3&0x3) + 1
+
+		b, err := d.bytes(offset, length)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var pointer uint
+
+		switch length {
+		case 1:
+			pointer = uint(ctrl&0x7)<<8 | uint(b[0])
+		case 2:
+			pointer = (uint(ctrl&0x7)<<16 | uint(bytesToUint(b))) + 2048
+		case 3:
+			pointer = (uint(ctrl&0x7)<<24 | uint(bytesToUint(b))) + 526336
+		case 4:
+			pointer = uint(bytesToUint(b))
+		}
+
+		value, _, err := d.decode(pointer)
+
+		return value, offset + length, err
+	case type_string:
+		b, err := d.bytes(offset, size)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return string(b), offset + size, nil
+	case type_double:
+		b, err := d.bytes(offset, 8)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset + 8, nil
+	case type_float:
+		b, err := d.bytes(offset, 4)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), offset + 4, nil
+	case type_bytes:
+		b, err := d.bytes(offset, size)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return b, offset + size, nil
+	case type_uint16, type_uint32, type_uint64:
+		b, err := d.bytes(offset, size)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return bytesToUint(b), offset + size, nil
+	case type_int32:
+		b, err := d.bytes(offset, size)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return int32(bytesToUint(b)), offset + size, nil
+	case type_uint128:
+		b, err := d.bytes(offset, size)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return new(big.Int).SetBytes(b), offset + size, nil
+	case type_boolean:
+		return size != 0, offset, nil
+	case type_map:
+		fields := make(map[string]any, size)
+
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset)
+
+			if err != nil {
+				return nil, 0, err
+			}
+
+			name, ok := key.(string)
+
+			if !ok {
+				return nil, 0, errors.New("invalid map key, expected a string")
+			}
+
+			value, next, err := d.decode(next)
+
+			if err != nil {
+				return nil, 0, err
+			}
+
+			fields[name] = value
+			offset = next
+		}
+
+		return fields, offset, nil
+	case type_array:
+		items := make([]any, 0, size)
+
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset)
+
+			if err != nil {
+				return nil, 0, err
+			}
+
+			items = append(items, value)
+			offset = next
+		}
+
+		return items, offset, nil
+	}
+
+	return nil, 0, fmt.Errorf("unsupported data type %d", kind)
+}
@@ -0,0 +1,227 @@
+package geoip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a minimal mmdb writer, only good enough to build the databases of the tests
+
+func encodeControl(kind int, size int) []byte {
+	extra := []byte{}
+
+	if size >= 29 {
+		extra = append(extra, byte(size-29))
+		size = 29
+	}
+
+	if kind > 7 {
+		return append([]byte{byte(size), byte(kind - 7)}, extra...)
+	}
+
+	return append([]byte{byte(kind<<5 | size)}, extra...)
+}
+
+func encode(value any) []byte {
+	switch v := value.(type) {
+	case string:
+		return append(encodeControl(type_string, len(v)), []byte(v)...)
+	case uint32:
+		return append(encodeControl(type_uint32, 4), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case uint16:
+		return append(encodeControl(type_uint16, 2), byte(v>>8), byte(v))
+	case bool:
+		if v {
+			return encodeControl(type_boolean, 1)
+		}
+
+		return encodeControl(type_boolean, 0)
+	case []any:
+		bytes := encodeControl(type_array, len(v))
+
+		for _, item := range v {
+			bytes = append(bytes, encode(item)...)
+		}
+
+		return bytes
+	case map[string]any:
+		bytes := encodeControl(type_map, len(v))
+
+		for key, item := range v {
+			bytes = append(bytes, encode(key)...)
+			bytes = append(bytes, encode(item)...)
+		}
+
+		return bytes
+	}
+
+	panic("unsupported value")
+}
+
+type testNode struct {
+	children [2]int
+	data     [2]int
+}
+
+type testNetwork struct {
+	ip     string
+	prefix int
+	record map[string]any
+}
+
+func buildDatabase(ipVersion int, recordSize int, networks []testNetwork) []byte {
+	nodes := []testNode{{children: [2]int{-1, -1}, data: [2]int{-1, -1}}}
+	data := make([]byte, 0)
+
+	for _, network := range networks {
+		offset := len(data)
+		data = append(data, encode(network.record)...)
+
+		address := net.ParseIP(network.ip).To4()
+		prefix := network.prefix
+
+		if ipVersion == 6 {
+			address = append(make([]byte, 12), address...)
+			prefix += 96
+		}
+
+		node := 0
+
+		for i := 0; i < prefix; i++ {
+			bit := int(address[i/8]>>(7-uint(i%8))) & 1
+
+			if i == prefix-1 {
+				nodes[node].data[bit] = offset
+				break
+			}
+
+			if nodes[node].children[bit] == -1 {
+				nodes = append(nodes, testNode{children: [2]int{-1, -1}, data: [2]int{-1, -1}})
+				nodes[node].children[bit] = len(nodes) - 1
+			}
+
+			node = nodes[node].children[bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	tree := make([]byte, 0)
+
+	for _, node := range nodes {
+		records := [2]uint32{}
+
+		for bit := 0; bit < 2; bit++ {
+			switch {
+			case node.children[bit] != -1:
+				records[bit] = uint32(node.children[bit])
+			case node.data[bit] != -1:
+				records[bit] = uint32(nodeCount + dataSectionSeparatorSize + node.data[bit])
+			default:
+				records[bit] = uint32(nodeCount)
+			}
+		}
+
+		switch recordSize {
+		case 24:
+			for _, r := range records {
+				tree = append(tree, byte(r>>16), byte(r>>8), byte(r))
+			}
+		case 28:
+			left, right := records[0], records[1]
+
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte((left>>24)<<4|(right>>24)&0x0F), byte(right>>16), byte(right>>8), byte(right))
+		case 32:
+			for _, r := range records {
+				tree = append(tree, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+			}
+		}
+	}
+
+	metadata := map[string]any{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+		"ip_version":                  uint16(ipVersion),
+		"database_type":               "Test",
+		"binary_format_major_version": uint16(2),
+		"languages":                   []any{"en"},
+	}
+
+	buffer := append(tree, make([]byte, dataSectionSeparatorSize)...)
+	buffer = append(buffer, data...)
+	buffer = append(buffer, metadataMarker...)
+	buffer = append(buffer, encode(metadata)...)
+
+	return buffer
+}
+
+var testNetworks = []testNetwork{
+	{
+		ip:     "81.2.69.0",
+		prefix: 24,
+		record: map[string]any{
+			"country": map[string]any{"iso_code": "GB"},
+			"city":    map[string]any{"names": map[string]any{"en": "London"}},
+		},
+	},
+	{
+		ip:     "1.128.0.0",
+		prefix: 11,
+		record: map[string]any{
+			"autonomous_system_number":       uint32(1221),
+			"autonomous_system_organization": "Telstra Pty Ltd",
+		},
+	},
+}
+
+func TestLookup(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			reader, err := FromBytes(buildDatabase(ipVersion, recordSize, testNetworks))
+
+			assert.Nil(t, err)
+			assert.Equal(t, uint(recordSize), reader.Metadata.RecordSize)
+			assert.Equal(t, "Test", reader.Metadata.DatabaseType)
+
+			record, err := reader.Lookup(net.ParseIP("81.2.69.160"))
+
+			assert.Nil(t, err)
+			assert.Equal(t, "London", lookupPath(record, "city", "names", "en"))
+
+			record, err = reader.Lookup(net.ParseIP("1.130.4.5"))
+
+			assert.Nil(t, err)
+			assert.Equal(t, uint64(1221), lookupPath(record, "autonomous_system_number"))
+
+			record, err = reader.Lookup(net.ParseIP("10.0.0.1"))
+
+			assert.Nil(t, err)
+			assert.Nil(t, record)
+		}
+	}
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	_, err := FromBytes([]byte("not a database"))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid mmdb file, metadata not found", err.Error())
+}
+
+func TestEnricher(t *testing.T) {
+	reader, err := FromBytes(buildDatabase(6, 28, testNetworks))
+
+	assert.Nil(t, err)
+
+	e := CreateEnricher().Add(reader)
+
+	assert.Equal(t, Location{Country: "GB", City: "London"}, e.Lookup("81.2.69.160"))
+	assert.Equal(t, Location{ASN: 1221, ASOrg: "Telstra Pty Ltd"}, e.Lookup("1.128.0.1"))
+	assert.Equal(t, Location{}, e.Lookup("not an ip"))
+
+	location, ok := e.cache.Get("81.2.69.160")
+
+	assert.True(t, ok)
+	assert.Equal(t, "GB", location.Country)
+}
@@ -2,11 +2,15 @@ module github.com/marcos-venicius/lfi
 
 go 1.22.3
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/marcos-venicius/quang v0.0.0-20250420173221-e87fd609ce5b
+	github.com/stretchr/testify v1.10.0
+	golang.org/x/term v0.27.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/marcos-venicius/quang v0.0.0-20250420173221-e87fd609ce5b // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// same sequences grep uses with --color
+const highlightStart = "\x1b[01;31m\x1b[K"
+const highlightEnd = "\x1b[m\x1b[K"
+
+func isTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+
+	if err != nil {
+		return false
+	}
+
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+func shouldColorize(mode string) (bool, error) {
+	switch mode {
+	case "auto":
+		return isTerminal(os.Stdout), nil
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("invalid color mode \"%s\". expected auto, always or never", mode)
+}
+
+// wraps every substring of value matched by any of the patterns with the
+// highlight sequences. overlapping matches are merged into a single region.
+func highlightMatches(value string, patterns []*regexp.Regexp) string {
+	if len(patterns) == 0 || len(value) == 0 {
+		return value
+	}
+
+	marked := make([]bool, len(value))
+	found := false
+
+	for _, pattern := range patterns {
+		for _, match := range pattern.FindAllStringIndex(value, -1) {
+			for i := match[0]; i < match[1]; i++ {
+				marked[i] = true
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return value
+	}
+
+	var builder strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			builder.WriteString(highlightStart)
+		}
+
+		builder.WriteByte(value[i])
+
+		if marked[i] && (i == len(value)-1 || !marked[i+1]) {
+			builder.WriteString(highlightEnd)
+		}
+	}
+
+	return builder.String()
+}
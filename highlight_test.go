@@ -0,0 +1,54 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlightMatches(t *testing.T) {
+	h := func(text string) string {
+		return highlightStart + text + highlightEnd
+	}
+
+	cases := []struct {
+		value    string
+		patterns []string
+		expected string
+	}{
+		{"/api/users", nil, "/api/users"},
+		{"", []string{"a"}, ""},
+		{"/api/users", []string{"orders"}, "/api/users"},
+		{"/api/users", []string{"^/api"}, h("/api") + "/users"},
+		{"/api/users", []string{"s"}, "/api/u" + h("s") + "er" + h("s")},
+		{"/api/users", []string{"api/u", "/users"}, "/" + h("api/users")},
+		{"/api/users", []string{"^/", "api"}, h("/api") + "/users"},
+	}
+
+	for _, c := range cases {
+		patterns := make([]*regexp.Regexp, 0)
+
+		for _, pattern := range c.patterns {
+			patterns = append(patterns, regexp.MustCompile(pattern))
+		}
+
+		assert.Equal(t, c.expected, highlightMatches(c.value, patterns), c.value)
+	}
+}
+
+func TestShouldColorize(t *testing.T) {
+	colorize, err := shouldColorize("always")
+
+	assert.Nil(t, err)
+	assert.True(t, colorize)
+
+	colorize, err = shouldColorize("never")
+
+	assert.Nil(t, err)
+	assert.False(t, colorize)
+
+	_, err = shouldColorize("sometimes")
+
+	assert.NotNil(t, err)
+}
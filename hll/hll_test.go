@@ -0,0 +1,44 @@
+package hll
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExactForSmallInputs(t *testing.T) {
+	c := Create(DEFAULT_EXACT_LIMIT)
+
+	for i := 0; i < 500; i++ {
+		c.Add(fmt.Sprintf("10.0.0.%d", i%250))
+	}
+
+	assert.True(t, c.Exact())
+	assert.Equal(t, uint64(250), c.Count())
+}
+
+func TestApproximateForBigInputs(t *testing.T) {
+	for _, n := range []int{5000, 100000, 1000000} {
+		c := Create(DEFAULT_EXACT_LIMIT)
+
+		for i := 0; i < n; i++ {
+			c.Add(fmt.Sprintf("user-%d", i))
+			c.Add(fmt.Sprintf("user-%d", i/2))
+		}
+
+		assert.False(t, c.Exact())
+		assert.InEpsilon(t, n, c.Count(), 0.03, "n = %d", n)
+	}
+}
+
+func TestAlwaysExact(t *testing.T) {
+	c := Create(-1)
+
+	for i := 0; i < 5000; i++ {
+		c.Add(fmt.Sprint(i))
+	}
+
+	assert.True(t, c.Exact())
+	assert.Equal(t, uint64(5000), c.Count())
+}
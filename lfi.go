@@ -9,23 +9,52 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
+	"github.com/marcos-venicius/lfi/bruteforce"
 	"github.com/marcos-venicius/lfi/formatter"
+	"github.com/marcos-venicius/lfi/geoip"
+	"github.com/marcos-venicius/lfi/openapi"
+	"github.com/marcos-venicius/lfi/otlp"
+	"github.com/marcos-venicius/lfi/session"
+	"github.com/marcos-venicius/lfi/threat"
+	"github.com/marcos-venicius/lfi/useragent"
+	"github.com/marcos-venicius/lfi/webhook"
 	"github.com/marcos-venicius/quang"
 )
 
 type lfi_t struct {
 	formatTokens []string
 	verbose      bool
+	highlights   map[string][]*regexp.Regexp
+	template     *template.Template
+
+	// param_<name> and segment_<ordinal> variables referenced by the query
+	dynamicVariables []string
+	onlyUndocumented bool
+
+	// when set, the matching logs are counted instead of displayed
+	report report_t
+
+	alerter *alerter_t
+	// where the matching logs are also sent, when set
+	webhook  *webhook.Sink
+	otlp     *otlp.Exporter
+	executor *executor_t
+	// only check the alerts, without showing the logs
+	watch bool
 
 	q *quang.Quang
 }
 
 type log_t struct {
-	ip         string
-	time       string
+	ip   string
+	time string
+	// the parsed time, zero when it's not valid
+	at         time.Time
 	method     quang.AtomType
 	host       string
 	resource   string
@@ -33,6 +62,32 @@ type log_t struct {
 	statusCode quang.IntegerType
 	size       quang.IntegerType
 	userAgent  string
+
+	path     string
+	query    string
+	ext      string
+	segments []string
+	params   url.Values
+	route    string
+
+	operationID string
+	// why the request is not declared in the openapi spec, "path" or "method"
+	undocumented string
+
+	agent    useragent.Agent
+	location geoip.Location
+	// the attack signature found in the resource or the user agent
+	threat threat.Match
+
+	// the named groups of the config regex
+	custom map[string]any
+
+	// shared by the copies of the log, so its values are built once
+	cache *log_cache_t
+}
+
+type log_cache_t struct {
+	values map[string]any
 }
 
 var wg sync.WaitGroup
@@ -55,6 +110,8 @@ const (
 	http_put_atom
 	http_options_atom
 	http_head_atom
+	bot_atom
+	human_atom
 )
 
 var atoms = map[string]quang.AtomType{
@@ -65,6 +122,8 @@ var atoms = map[string]quang.AtomType{
 	":put":     http_put_atom,
 	":options": http_options_atom,
 	":head":    http_head_atom,
+	":bot":     bot_atom,
+	":human":   human_atom,
 }
 
 func methodDisplay(method quang.AtomType) string {
@@ -110,22 +169,19 @@ func stringMethodToType(method string) (quang.AtomType, error) {
 }
 
 func parseKongLogLine(line string, breakParamsOut bool, configs *Configs) (log_t, error) {
-	log := log_t{}
-
-	positions := make(map[order_t]int)
+	log := log_t{cache: &log_cache_t{}}
 
-	for p, key := range configs.order {
-		positions[key] = p + 1
-	}
+	positions := configs.positions
 
 	matches := configs.regex.FindStringSubmatch(line)
 
-	if len(matches) != 10 {
+	if matches == nil {
 		return log, errors.New(line)
 	}
 
 	log.ip = matches[positions[ORDER_IP]]
 	log.time = matches[positions[ORDER_TIME]]
+	log.at, _ = time.Parse(logTimeLayout, log.time)
 
 	if method, err := stringMethodToType(matches[positions[ORDER_METHOD]]); err == nil {
 		log.method = method
@@ -144,6 +200,19 @@ func parseKongLogLine(line string, breakParamsOut bool, configs *Configs) (log_t
 	} else {
 		log.resource = matches[positions[ORDER_RESOURCE]]
 	}
+
+	parseResource(&log, matches[positions[ORDER_RESOURCE]], configs.routes)
+
+	if configs.spec != nil {
+		operation, status := configs.spec.Match(methodDisplay(log.method), log.path)
+
+		log.operationID = operation.ID
+		log.undocumented = status.String()
+
+		if len(operation.Route) > 0 {
+			log.route = operation.Route
+		}
+	}
 	log.version = matches[positions[ORDER_HTTP_VERSION]]
 
 	if n, err := strconv.ParseInt(matches[positions[ORDER_STATUS_CODE]], 10, 32); err == nil {
@@ -161,43 +230,243 @@ func parseKongLogLine(line string, breakParamsOut bool, configs *Configs) (log_t
 	log.host = matches[positions[ORDER_HOST]]
 
 	log.userAgent = matches[positions[ORDER_USER_AGENT]]
+	log.agent = configs.agents.Classify(log.userAgent)
+	if !configs.skipThreats {
+		log.threat = configs.threats.Detect(matches[positions[ORDER_RESOURCE]], log.userAgent)
+	}
+
+	if len(configs.fields) > 0 {
+		log.custom = make(map[string]any, len(configs.fields))
+
+		for name, group := range configs.fields {
+			log.custom[name] = parseCustomField(matches[group])
+		}
+	}
+
+	if !configs.geo.Empty() {
+		log.location = configs.geo.Lookup(log.ip)
+	}
 
 	return log, nil
 }
 
-func displayLogsBasedOnFormatting(tokens []string, log log_t) {
+// the time of the log, or now when it's not valid. old logs are judged by
+// their own time, so files can be replayed
+func (l log_t) judgedTime() time.Time {
+	if l.at.IsZero() {
+		return time.Now()
+	}
+
+	return l.at
+}
+
+// the variables available to templates, named just like the quang variables.
+// the map is built once per log and shared, so it must not be changed
+func (l log_t) values() map[string]any {
+	if l.cache != nil && l.cache.values != nil {
+		return l.cache.values
+	}
+
+	values := map[string]any{
+		"time":     l.time,
+		"ip":       l.ip,
+		"method":   methodDisplay(l.method),
+		"resource": l.resource,
+		"version":  l.version,
+		"status":   l.statusCode,
+		"size":     l.size,
+
+		"status_class": statusClass(l.statusCode),
+		"host":         l.host,
+		"agent":        l.userAgent,
+
+		"operation_id": l.operationID,
+		"documented":   len(l.undocumented) == 0,
+		"undocumented": l.undocumented,
+
+		"ua_browser": l.agent.Browser,
+		"ua_version": l.agent.Version,
+		"ua_os":      l.agent.OS,
+		"ua_device":  l.agent.Device,
+		"ua_bot":     uaBot(l.agent),
+
+		"country": l.location.Country,
+		"city":    l.location.City,
+		"asn":     quang.IntegerType(l.location.ASN),
+		"as_org":  l.location.ASOrg,
+
+		"threat":      l.threat.Category,
+		"threat_rule": l.threat.Rule,
+	}
+
+	for name, value := range l.custom {
+		values[name] = value
+	}
+
+	l.addResourceValues(values)
+
+	if l.cache != nil {
+		l.cache.values = values
+	}
+
+	return values
+}
+
+// the atom of ua_bot in queries
+func uaBotAtom(agent useragent.Agent) quang.AtomType {
+	if agent.Bot {
+		return bot_atom
+	}
+
+	return human_atom
+}
+
+// ua_bot in the output, where atoms can't be shown
+func uaBot(agent useragent.Agent) string {
+	if agent.Bot {
+		return "yes"
+	}
+
+	return "no"
+}
+
+// 404 -> "4xx"
+func statusClass(status quang.IntegerType) string {
+	if status < 100 || status > 999 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// custom fields are integers or floats when they look like one
+func parseCustomField(value string) any {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return quang.IntegerType(n)
+	}
+
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return quang.FloatType(n)
+	}
+
+	return value
+}
+
+// the log is rendered before it's printed, so a template failing in the
+// middle doesn't leave half of a line
+func displayLogsBasedOnTemplate(w io.Writer, t *template.Template, log log_t) error {
+	var builder strings.Builder
+
+	if err := t.Execute(&builder, log.values()); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w, builder.String())
+
+	return err
+}
+
+func displayLogsBasedOnFormatting(tokens []string, log log_t, highlights map[string][]*regexp.Regexp) {
+	formatLog(os.Stdout, tokens, log, highlights)
+
+	fmt.Println()
+}
+
+func formatLog(w io.Writer, tokens []string, log log_t, highlights map[string][]*regexp.Regexp) {
+	values := log.values()
+
 	for _, token := range tokens {
-		if token[0] == '\'' {
-			fmt.Print(token[1 : len(token)-1])
-		} else if token[0] == ' ' {
-			fmt.Print(token)
-		} else {
-			switch token {
-			case "%time":
-				fmt.Print(log.time)
-			case "%ip":
-				fmt.Print(log.ip)
-			case "%method":
-				fmt.Print(methodDisplay(log.method))
-			case "%resource":
-				fmt.Print(log.resource)
-			case "%version":
-				fmt.Print(log.version)
-			case "%status":
-				fmt.Print(log.statusCode)
-			case "%size":
-				fmt.Print(log.size)
-			case "%host":
-				fmt.Print(log.host)
-			case "%agent":
-				fmt.Print(log.userAgent)
-			default:
-				fmt.Print(token)
+		if len(token) > 1 && token[0] == '\'' {
+			fmt.Fprint(w, formatter.Unquote(token))
+		} else if len(token) > 1 && token[0] == '%' {
+			name := token[1:]
+
+			if value, ok := values[name]; ok {
+				if text, ok := value.(string); ok {
+					fmt.Fprint(w, highlightMatches(text, highlights[name]))
+				} else {
+					fmt.Fprint(w, value)
+				}
+			} else if value, ok := log.dynamicField(name); ok {
+				fmt.Fprint(w, value)
+			} else {
+				fmt.Fprint(w, token)
 			}
+		} else {
+			fmt.Fprint(w, token)
 		}
 	}
+}
 
-	fmt.Println()
+// the log formatted as a single line, without colors
+func formatLine(tokens []string, t *template.Template, log log_t) string {
+	var builder strings.Builder
+
+	if t != nil {
+		if err := t.Execute(&builder, log.values()); err != nil {
+			return err.Error()
+		}
+	} else {
+		formatLog(&builder, tokens, log, nil)
+	}
+
+	return strings.ReplaceAll(builder.String(), "\n", " ")
+}
+
+// every string and integer value becomes a quang variable with the same
+// name. they are bound one by one, without building the values of the log
+func (l log_t) bindQueryVariables(q *quang.Quang, dynamicVariables []string) {
+	q.AddStringVar("time", l.time)
+	q.AddStringVar("ip", l.ip)
+	q.AddAtomVar("method", l.method)
+	q.AddStringVar("resource", l.resource)
+	q.AddStringVar("version", l.version)
+	q.AddIntegerVar("status", l.statusCode)
+	q.AddIntegerVar("size", l.size)
+
+	q.AddStringVar("status_class", statusClass(l.statusCode))
+	q.AddStringVar("host", l.host)
+	q.AddStringVar("agent", l.userAgent)
+
+	q.AddStringVar("path", l.path)
+	q.AddStringVar("query", l.query)
+	q.AddStringVar("ext", l.ext)
+	q.AddStringVar("route", l.route)
+	q.AddIntegerVar("segments", quang.IntegerType(len(l.segments)))
+
+	q.AddStringVar("operation_id", l.operationID)
+	q.AddStringVar("undocumented", l.undocumented)
+
+	q.AddStringVar("ua_browser", l.agent.Browser)
+	q.AddStringVar("ua_version", l.agent.Version)
+	q.AddStringVar("ua_os", l.agent.OS)
+	q.AddStringVar("ua_device", l.agent.Device)
+	q.AddAtomVar("ua_bot", uaBotAtom(l.agent))
+
+	q.AddStringVar("country", l.location.Country)
+	q.AddStringVar("city", l.location.City)
+	q.AddIntegerVar("asn", quang.IntegerType(l.location.ASN))
+	q.AddStringVar("as_org", l.location.ASOrg)
+
+	q.AddStringVar("threat", l.threat.Category)
+	q.AddStringVar("threat_rule", l.threat.Rule)
+
+	for name, value := range l.custom {
+		switch v := value.(type) {
+		case string:
+			q.AddStringVar(name, v)
+		case quang.IntegerType:
+			q.AddIntegerVar(name, v)
+		case quang.FloatType:
+			q.AddFloatVar(name, v)
+		}
+	}
+
+	for _, name := range dynamicVariables {
+		value, _ := l.dynamicField(name)
+
+		q.AddStringVar(name, value)
+	}
 }
 
 func (l lfi_t) worker(logs chan []byte, breakParamsOut bool, configs *Configs) {
@@ -219,15 +488,15 @@ func (l lfi_t) worker(logs chan []byte, breakParamsOut bool, configs *Configs) {
 				fmt.Println(err)
 			}
 		} else {
-			l.q.AddStringVar("time", log.time).
-				AddStringVar("ip", log.ip).
-				AddAtomVar("method", log.method).
-				AddStringVar("resource", log.resource).
-				AddStringVar("version", log.version).
-				AddIntegerVar("status", log.statusCode).
-				AddIntegerVar("size", log.size).
-				AddStringVar("host", log.host).
-				AddStringVar("agent", log.userAgent)
+			if l.alerter != nil {
+				l.alerter.add(log)
+			}
+
+			if l.watch {
+				continue
+			}
+
+			log.bindQueryVariables(l.q, l.dynamicVariables)
 
 			show, err := l.q.Eval()
 
@@ -235,7 +504,25 @@ func (l lfi_t) worker(logs chan []byte, breakParamsOut bool, configs *Configs) {
 				fmt.Println(err.Error())
 			}
 
-			if show {
+			if l.onlyUndocumented && len(log.undocumented) == 0 {
+				show = false
+			}
+
+			if show && l.webhook != nil {
+				l.webhook.Send(logEvent(l.formatTokens, l.template, log))
+			}
+
+			if show && l.otlp != nil {
+				l.otlp.Send(logRecord(l.formatTokens, l.template, log))
+			}
+
+			if show && l.executor != nil {
+				l.executor.add(log)
+			}
+
+			if show && l.report != nil {
+				l.report.add(log)
+			} else if show {
 				if logsTimeout != 0 {
 					now := time.Now().UnixMilli()
 					diff := int(now - lastUpdateTime)
@@ -249,10 +536,143 @@ func (l lfi_t) worker(logs chan []byte, breakParamsOut bool, configs *Configs) {
 					}
 				}
 
-				displayLogsBasedOnFormatting(l.formatTokens, log)
+				if l.template != nil {
+					if err := displayLogsBasedOnTemplate(os.Stdout, l.template, log); err != nil {
+						fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+					}
+				} else {
+					displayLogsBasedOnFormatting(l.formatTokens, log, l.highlights)
+				}
+			}
+		}
+	}
+}
+
+// the flags shared by the subcommands that read logs, like "baseline learn"
+type log_flags_t struct {
+	query          *string
+	breakParamsOut *bool
+	spec           *string
+}
+
+func addLogFlags(flags *flag.FlagSet) log_flags_t {
+	return log_flags_t{
+		query:          flags.String("q", "", "only use the logs matching this quang filter, like \"path ne '/health'\""),
+		breakParamsOut: flags.Bool("s", false, "strip out params from resource"),
+		spec:           flags.String("openapi", "", "openapi (or swagger) spec file, used to find the route of each log"),
+	}
+}
+
+// the configs and the query of the flags
+func (f log_flags_t) load() (*Configs, *quang.Quang, []string, error) {
+	configs, err := LoadConfigs()
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(*f.spec) > 0 {
+		configs.spec, err = openapi.Load(*f.spec)
+
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	q, err := compileQuery(*f.query)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return configs, q, dynamicQueryVariables(*f.query), nil
+}
+
+// reads the logs of input, calling fn with the ones matching the query and
+// their time, zero when it's not valid
+func readMatchingLogs(input io.Reader, breakParamsOut bool, configs *Configs, q *quang.Quang, dynamicVariables []string, fn func(log log_t, t time.Time)) {
+	lines := make(chan []byte, 1024)
+
+	go func() {
+		readLines(input, lines)
+		close(lines)
+	}()
+
+	for line := range lines {
+		log, err := parseKongLogLine(string(line), breakParamsOut, configs)
+
+		if err != nil {
+			continue
+		}
+
+		log.bindQueryVariables(q, dynamicVariables)
+
+		if matches, err := q.Eval(); err == nil && matches {
+			fn(log, log.at)
+		}
+	}
+}
+
+// sends every line of the reader to logs, until the end of it
+func readLines(reader io.Reader, logs chan []byte) {
+	bytes := make([]byte, 256)
+
+	line := make([]byte, 0, 1024)
+	lineSize := 0
+
+	for {
+		n, err := reader.Read(bytes)
+
+		if err == io.EOF {
+			break
+		}
+
+		for i := range n {
+			if bytes[i] == '\n' {
+				logs <- line[:lineSize]
+
+				line = make([]byte, 0, 1024)
+				lineSize = 0
+			} else {
+				line = append(line, bytes[i])
+				lineSize += 1
 			}
 		}
+
+		bytes = make([]byte, 256)
+	}
+}
+
+func createLogFormatter(configs *Configs) formatter.Formatter {
+	labels := []string{"time", "ip", "method", "resource", "version", "status", "status_class", "size", "host", "agent", "path", "query", "ext", "segments", "route", "operation_id", "undocumented", "ua_browser", "ua_version", "ua_os", "ua_device", "ua_bot", "country", "city", "asn", "as_org", "threat", "threat_rule"}
+
+	labels = append(labels, segmentLabels()...)
+
+	for name := range configs.fields {
+		labels = append(labels, name)
 	}
+
+	logFormatter := formatter.CreateFormatter(labels)
+
+	logFormatter.AddLabelPrefix("param_")
+
+	return logFormatter
+}
+
+func compileQuery(query string) (*quang.Quang, error) {
+	q, err := quang.Init(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateQueryPatterns(query); err != nil {
+		return nil, err
+	}
+
+	q.SetupAtoms(atoms)
+
+	return q, nil
 }
 
 func isFlagParsed(name string) bool {
@@ -267,12 +687,63 @@ func isFlagParsed(name string) bool {
 	return found
 }
 
+// subcommands, like "lfi top", have their own flags
+var commands = map[string]func(args []string) int{
+	"top":           runTop,
+	"browse":        runBrowse,
+	"baseline":      runBaseline,
+	"diff":          runDiff,
+	"serve-metrics": runServeMetrics,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	verbose := flag.Bool("v", false, "when verbose mode is activated all errors will be shown")
 	format := flag.String("f", defaultFormatting, "format the log in a specific way")
 	timeout := flag.Int("t", 0, "timeout between logs. it's usefull when yours logs are crazingly fast. specify it in milliseconds")
-	query := flag.String("q", "", "provide any valid filter using quang syntax https://github.com/marcos-venicius/quang.\navailable variables: time, ip, method, resource, version, status, size, host, agent.\navailable method atoms :get, :post, :delete, :patch, :put, :options.")
+	query := flag.String("q", "", "provide any valid filter using quang syntax https://github.com/marcos-venicius/quang.\navailable variables: time, ip, method, resource, version, status, status_class, size, host, agent, path, query, ext, route, segments, segment_<one..ten>, param_<name>, operation_id, undocumented, ua_browser, ua_version, ua_os, ua_device, ua_bot, country, city, asn, as_org, threat, threat_rule.\navailable method atoms :get, :post, :delete, :patch, :put, :options, :head. ua_bot is :bot or :human.")
 	breakParamsOut := flag.Bool("s", false, "strip out params from resource. everything like 'url<?param=value>' is going to be removed")
+	templateText := flag.String("template", "", "format the log using a go text/template. it takes precedence over -f and the config format")
+	spec := flag.String("openapi", "", "openapi (or swagger) spec file, in yaml or json, used to find the operation of each log")
+	undocumented := flag.Bool("undocumented", false, "only show the logs whose path or method is not declared in the openapi spec")
+	groupBy := flag.String("group-by", "", "count the matching logs by a comma separated list of fields, like \"ip,status\", and show them at the end instead of the logs")
+	aggs := flag.String("agg", "", "aggregate numeric fields of the matching logs, per -group-by group or globally. like \"sum(size), avg(size), p95(size)\".\navailable aggregations: count(), sum, avg, min, max, count_distinct and percentiles from p0 to p100")
+	exact := flag.Bool("exact", false, "always count_distinct exactly. by default it switches to an approximation, with around 1% of error, after 1000 distinct values")
+	top := flag.Int("top", 0, "only show the n groups with the biggest counts of -group-by, or the n first ips of -threat-report and -bruteforce, or the n sessions with the most requests of -sessions")
+	output := flag.String("output", "table", "output of -group-by, -agg, -bucket, -threat-report, -bruteforce, -sessions and -slo. one of table, json or csv")
+	bucket := flag.Duration("bucket", 0, "count the matching logs per time bucket, like 1m or 1h, using the time of the logs, and show a chart at the end instead of the logs")
+	split := flag.String("split", "", "split the counts of each -bucket by a field, like status_class or method")
+	interval := flag.Duration("interval", 0, "also show the -group-by, -agg, -bucket, -threat-report, -bruteforce, -sessions and -slo results every interval, like 10s, which is useful when following logs")
+	execCommand := flag.String("exec", "", "run a shell command for each matching log. {field} placeholders, like 'ban-ip {ip}', are replaced by the values of the log, passed as arguments so the shell never parses them")
+	execBatch := flag.String("exec-batch", "", "run a shell command with a batch of matching logs, formatted like the output, in its stdin")
+	execBatchSize := flag.Int("exec-batch-size", 100, "the maximum number of logs of each -exec-batch")
+	execBatchInterval := flag.Duration("exec-batch-interval", 5*time.Second, "how often the logs waiting for -exec-batch are sent, even when the batch is not full")
+	execConcurrency := flag.Int("exec-concurrency", 4, "how many -exec and -exec-batch commands can run at the same time")
+	execTimeout := flag.Duration("exec-timeout", 30*time.Second, "commands of -exec and -exec-batch running for longer are killed")
+	webhookURL := flag.String("webhook", "", "also send the matching logs, and the alerts, to an http endpoint in batches")
+	webhookFormat := flag.String("webhook-format", "json", "format of the -webhook requests. one of json or slack")
+	webhookSpool := flag.String("webhook-spool", "", "a directory where the -webhook batches are kept while the endpoint is down, to be sent later")
+	otlpEndpoint := flag.String("otlp", "", "also send the matching logs as opentelemetry log records, in batches, to an otlp/http endpoint, like http://localhost:4318")
+	otlpHeaders := flag.String("otlp-headers", "", "headers of the -otlp requests, like \"authorization=Bearer%20token,x-team=web\"")
+	otlpService := flag.String("otlp-service", otlp.DEFAULT_SERVICE_NAME, "the service.name of the -otlp records")
+	threatReport := flag.Bool("threat-report", false, "show the ips with attack signatures, like sqli or xss, with how many of each, at the end instead of the logs")
+	bruteforceReport := flag.Bool("bruteforce", false, "show the ips failing to login too often, like many 401 or 403 on login routes, ranked, at the end instead of the logs")
+	bruteforceThreshold := flag.Int("bruteforce-threshold", bruteforce.DEFAULT_THRESHOLD, "failed logins of an ip on a route, within -bruteforce-window, that flag it")
+	bruteforceRouteThreshold := flag.Int("bruteforce-route-threshold", bruteforce.DEFAULT_ROUTE_THRESHOLD, "failed logins of a route, from every ip, within -bruteforce-window, that flag it")
+	bruteforceWindow := flag.Duration("bruteforce-window", bruteforce.DEFAULT_WINDOW, "the sliding window of -bruteforce")
+	sessionsReport := flag.Bool("sessions", false, "group the matching logs into sessions of each client, and show a summary of each, with the most requests first, at the end instead of the logs")
+	sessionKey := flag.String("session-key", "ip,agent", "the comma separated list of fields that identify a client of -sessions")
+	sessionTimeout := flag.Duration("session-timeout", session.DEFAULT_TIMEOUT, "the inactivity after which the next request of a client starts a new session")
+	sloReport := flag.Bool("slo", false, "show the compliance, the error budget and the burn rates of the slos of the config file at the end instead of the logs")
+	sloWindows := flag.String("slo-windows", "5m,1h,6h,24h", "the comma separated windows of the burn rates of -slo, ending at the last log")
+	watch := flag.Bool("watch", false, "only check the alerts of the config file, without showing the logs")
+	checkAlerts := flag.Bool("alerts", false, "also check the alerts of the config file while showing the logs")
+	color := flag.String("color", "auto", "highlight the substrings matched by 'reg' comparisons of the query. one of auto, always or never")
 
 	flag.Parse()
 
@@ -286,7 +757,21 @@ func main() {
 		os.Exit(1)
 	}
 
-	logFormatter := formatter.CreateFormatter([]string{"time", "ip", "method", "resource", "version", "status", "size", "host", "agent"})
+	if isFlagParsed("openapi") {
+		configs.spec, err = openapi.Load(*spec)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *undocumented && configs.spec == nil {
+		fmt.Fprintln(os.Stderr, "error: -undocumented needs an openapi spec. use -openapi or \"openapi = \" in the config file")
+		os.Exit(1)
+	}
+
+	logFormatter := createLogFormatter(configs)
 
 	var formatting string = configs.format
 
@@ -301,53 +786,349 @@ func main() {
 		os.Exit(1)
 	}
 
-	q, err := quang.Init(*query)
+	var logTemplate *template.Template
+
+	var templateSource string = configs.template
+
+	if isFlagParsed("f") {
+		templateSource = ""
+	}
+
+	if isFlagParsed("template") {
+		templateSource = *templateText
+	}
+
+	if len(templateSource) > 0 {
+		logTemplate, err = parseTemplate(templateSource)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	q, err := compileQuery(*query)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	colorize, err := shouldColorize(*color)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
 		os.Exit(1)
 	}
 
-	q.SetupAtoms(atoms)
+	highlights := make(map[string][]*regexp.Regexp)
+
+	if colorize {
+		highlights, err = queryRegexPatterns(*query)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *bucket > 0 && len(*groupBy) > 0 {
+		fmt.Fprintln(os.Stderr, "error: -bucket cannot be used with -group-by, use -split instead")
+		os.Exit(1)
+	}
+
+	// -agg aggregates the buckets of -bucket, or the groups of -group-by,
+	// which are a single group without it
+	grouping := "-group-by"
+
+	if len(*groupBy) == 0 {
+		grouping = "-agg"
+	}
+
+	modes := []struct {
+		flag string
+		used bool
+	}{
+		{grouping, len(*groupBy) > 0 || (len(*aggs) > 0 && *bucket == 0)},
+		{"-bucket", *bucket > 0},
+		{"-threat-report", *threatReport},
+		{"-bruteforce", *bruteforceReport},
+		{"-sessions", *sessionsReport},
+		{"-slo", *sloReport},
+	}
+
+	used := make([]string, 0)
+
+	for _, mode := range modes {
+		if mode.used {
+			used = append(used, mode.flag)
+		}
+	}
+
+	if len(used) > 1 {
+		fmt.Fprintf(os.Stderr, "error: %s and %s cannot be used together, only one report can be shown\n", strings.Join(used[:len(used)-1], ", "), used[len(used)-1])
+		os.Exit(1)
+	}
+
+	if len(used) > 0 {
+		if err := validateOutputFormat(*output); err != nil {
+			fmt.Fprintf(os.Stderr, "error: -output: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	var report report_t
+
+	switch {
+	case *bucket > 0:
+		if *bucket < time.Second {
+			fmt.Fprintln(os.Stderr, "error: -bucket should be at least 1s")
+			os.Exit(1)
+		}
+
+		aggregations := make([]agg_t, 0)
+
+		if len(*split) > 0 && !isKnownField(*split, configs) {
+			fmt.Fprintf(os.Stderr, "error: -split: unknown field \"%s\"\n", *split)
+			os.Exit(1)
+		}
+
+		if len(*aggs) > 0 {
+			aggregations, err = parseAggs(*aggs, configs, *exact)
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: -agg: %s\n", err.Error())
+				os.Exit(1)
+			}
+		}
+
+		report = createBucketer(*bucket, *split, aggregations, *output)
+	case len(*groupBy) > 0 || len(*aggs) > 0:
+		fields := make([]string, 0)
+		aggregations := make([]agg_t, 0)
+
+		if len(*groupBy) > 0 {
+			fields, err = parseFieldList(*groupBy, configs)
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: -group-by: %s\n", err.Error())
+				os.Exit(1)
+			}
+		}
+
+		if len(*aggs) > 0 {
+			aggregations, err = parseAggs(*aggs, configs, *exact)
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: -agg: %s\n", err.Error())
+				os.Exit(1)
+			}
+		}
+
+		report = createAggregator(fields, aggregations, *top, *output)
+	case *threatReport:
+		report = createThreatReport(*top, *output)
+	case *bruteforceReport:
+		if *bruteforceThreshold < 1 || *bruteforceRouteThreshold < 1 || *bruteforceWindow < time.Second {
+			fmt.Fprintln(os.Stderr, "error: -bruteforce-threshold and -bruteforce-route-threshold should be at least 1, and -bruteforce-window at least 1s")
+			os.Exit(1)
+		}
+
+		report = createBruteforceReport(configs, bruteforce.Options{
+			Threshold:      *bruteforceThreshold,
+			RouteThreshold: *bruteforceRouteThreshold,
+			Window:         *bruteforceWindow,
+		}, *top, *output)
+	case *sessionsReport:
+		fields, err := parseFieldList(*sessionKey, configs)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -session-key: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		if *sessionTimeout <= 0 {
+			fmt.Fprintln(os.Stderr, "error: -session-timeout should be positive")
+			os.Exit(1)
+		}
+
+		report = createSessionReport(fields, *sessionTimeout, *top, *output)
+	case *sloReport:
+		if len(configs.slos) == 0 {
+			fmt.Fprintln(os.Stderr, "error: -slo needs slos. add them with \"slo = \" in the config file")
+			os.Exit(1)
+		}
+
+		windows, err := parseWindows(*sloWindows)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -slo-windows: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		report, err = createSLOReport(configs.slos, windows, *output)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	var alerter *alerter_t
+
+	// the alerts of the config are only checked when asked for, so a config
+	// with alerts doesn't fire them on every run
+	if *watch || *checkAlerts {
+		if len(configs.alerts) == 0 {
+			fmt.Fprintln(os.Stderr, "error: -watch and -alerts need alerts. add them with \"alert = \" in the config file")
+			os.Exit(1)
+		}
+
+		alerter, err = createAlerter(configs.alerts, configs.alertFile)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	var sink *webhook.Sink
+
+	if len(*webhookURL) > 0 {
+		sink, err = createWebhook(*webhookURL, *webhookFormat, *webhookSpool)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -webhook: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		if alerter != nil {
+			alerter.webhook = sink
+		}
+	}
+
+	var exporter *otlp.Exporter
+
+	if len(*otlpEndpoint) > 0 {
+		exporter, err = createOTLPExporter(*otlpEndpoint, *otlpHeaders, *otlpService)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -otlp: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	var executor *executor_t
+
+	if len(*execCommand) > 0 || len(*execBatch) > 0 {
+		if err := validatePlaceholders(*execCommand, configs); err != nil {
+			fmt.Fprintf(os.Stderr, "error: -exec: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		if *execBatchSize < 1 || *execConcurrency < 1 {
+			fmt.Fprintln(os.Stderr, "error: -exec-batch-size and -exec-concurrency should be at least 1")
+			os.Exit(1)
+		}
+
+		if *execTimeout <= 0 || *execBatchInterval <= 0 {
+			fmt.Fprintln(os.Stderr, "error: -exec-timeout and -exec-batch-interval should be positive")
+			os.Exit(1)
+		}
+
+		executor = createExecutor(*execCommand, *execBatch, *execBatchSize, *execBatchInterval, *execConcurrency, *execTimeout, tokens, logTemplate)
+	}
+
+	// the webhook events have every field, threat included
+	if !*threatReport && sink == nil {
+		texts := []string{*query, formatting, templateSource, *groupBy, *split, *aggs, *execCommand}
+
+		if *sessionsReport {
+			texts = append(texts, *sessionKey)
+		}
+
+		if alerter != nil {
+			for _, rule := range configs.alerts {
+				texts = append(texts, rule.Query, rule.Per)
+			}
+		}
+
+		if *sloReport {
+			for _, definition := range configs.slos {
+				texts = append(texts, definition.Selector, definition.Good)
+			}
+		}
+
+		configs.skipThreats = !mentionsThreat(texts...)
+	}
 
 	logs := make(chan []byte, 0)
 
 	lfi := lfi_t{
 		formatTokens: tokens,
 		verbose:      *verbose,
+		highlights:   highlights,
+		template:     logTemplate,
 		q:            q,
+
+		dynamicVariables: dynamicQueryVariables(*query),
+		onlyUndocumented: *undocumented,
+		report:           report,
+		alerter:          alerter,
+		webhook:          sink,
+		otlp:             exporter,
+		executor:         executor,
+		watch:            *watch,
 	}
 
 	wg.Add(1)
 	go lfi.worker(logs, *breakParamsOut, configs)
 
-	bytes := make([]byte, 256)
+	var stopReports, reportsDone chan struct{}
 
-	line := make([]byte, 0, 1024)
-	lineSize := 0
+	if report != nil && *interval > 0 {
+		stopReports = make(chan struct{})
+		reportsDone = make(chan struct{})
 
-	for {
-		n, err := os.Stdin.Read(bytes)
+		go reportPeriodically(report, *output, *interval, stopReports, reportsDone)
+	}
 
-		if err == io.EOF {
-			break
-		}
+	readLines(os.Stdin, logs)
 
-		for i := range n {
-			if bytes[i] == '\n' {
-				logs <- line[:lineSize]
+	close(logs)
+	wg.Wait()
 
-				line = make([]byte, 0, 1024)
-				lineSize = 0
-			} else {
-				line = append(line, bytes[i])
-				lineSize += 1
-			}
-		}
+	// a report still being written would be mixed with the last one
+	if stopReports != nil {
+		close(stopReports)
+		<-reportsDone
+	}
 
-		bytes = make([]byte, 256)
+	if report != nil {
+		report.write(os.Stdout)
 	}
 
-	close(logs)
-	wg.Wait()
+	if alerter != nil {
+		alerter.wait()
+	}
+
+	if executor != nil {
+		executor.wait()
+	}
+
+	if sink != nil {
+		sink.Close()
+
+		if dropped := sink.Dropped(); dropped > 0 {
+			fmt.Fprintf(os.Stderr, "error: webhook: %d events were dropped because the queue was full\n", dropped)
+		}
+	}
+
+	if exporter != nil {
+		exporter.Close()
+
+		if dropped := exporter.Dropped(); dropped > 0 {
+			fmt.Fprintf(os.Stderr, "error: otlp: %d records were dropped because the queue was full\n", dropped)
+		}
+	}
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/marcos-venicius/lfi/geoip"
+	"github.com/marcos-venicius/lfi/route"
+	"github.com/marcos-venicius/lfi/threat"
+	"github.com/marcos-venicius/lfi/useragent"
+	"github.com/marcos-venicius/quang"
+	"github.com/stretchr/testify/assert"
+)
+
+// the configs of a fresh install, without reading the config file
+func defaultConfigs(t *testing.T) *Configs {
+	configs := &Configs{
+		regex:   defaultLogRegex,
+		order:   defaultOrder,
+		format:  defaultFormatting,
+		routes:  route.CreateMatcher(),
+		agents:  useragent.Default(),
+		threats: threat.Default(),
+		geo:     geoip.CreateEnricher(),
+	}
+
+	assert.Nil(t, configs.indexGroups())
+
+	return configs
+}
+
+const testLine = `10.0.0.1 - - [10/Oct/2024:13:55:36 +0000] "GET /api/users/42?page=2 HTTP/1.1" 404 512 "example.com" "Googlebot/2.1 (+http://www.google.com/bot.html)"`
+
+func testLog(t *testing.T) log_t {
+	log, err := parseKongLogLine(testLine, false, defaultConfigs(t))
+
+	assert.Nil(t, err)
+
+	return log
+}
+
+// a query comparing the variable with the value, like "status eq 404"
+func equalsQuery(name string, value any) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%s eq '%s'", name, v)
+	case quang.IntegerType:
+		return fmt.Sprintf("%s eq %d", name, v)
+	}
+
+	return ""
+}
+
+func TestBindQueryVariables(t *testing.T) {
+	log := testLog(t)
+
+	for name, value := range log.values() {
+		query := equalsQuery(name, value)
+
+		// the method and ua_bot are atoms in queries, and the documented bool can't be compared
+		if len(query) == 0 || name == "method" || name == "ua_bot" {
+			continue
+		}
+
+		q, err := compileQuery(query)
+
+		assert.Nil(t, err, query)
+
+		log.bindQueryVariables(q, dynamicQueryVariables(query))
+
+		matches, err := q.Eval()
+
+		assert.Nil(t, err, query)
+		assert.True(t, matches, query)
+	}
+
+	query := "method eq :get and ua_bot eq :bot and segment_three eq '42' and param_page eq '2'"
+	q, _ := compileQuery(query)
+
+	log.bindQueryVariables(q, dynamicQueryVariables(query))
+
+	matches, err := q.Eval()
+
+	assert.Nil(t, err)
+	assert.True(t, matches)
+
+	q, _ = compileQuery("ua_bot eq :human")
+
+	log.bindQueryVariables(q, nil)
+
+	matches, err = q.Eval()
+
+	assert.Nil(t, err)
+	assert.False(t, matches)
+}
+
+func TestValuesBuiltOnce(t *testing.T) {
+	log := testLog(t)
+	copied := log
+
+	values := log.values()
+
+	assert.Equal(t, "yes", values["ua_bot"])
+	assert.Equal(t, "GET", values["method"])
+	assert.Equal(t, "42", values["segment_three"])
+	assert.Equal(t, fmt.Sprintf("%p", values), fmt.Sprintf("%p", copied.values()))
+}
+
+func TestJudgedTime(t *testing.T) {
+	log := testLog(t)
+
+	assert.Equal(t, time.Date(2024, 10, 10, 13, 55, 36, 0, time.UTC).Unix(), log.at.Unix())
+	assert.Equal(t, log.at, log.judgedTime())
+
+	log.at = time.Time{}
+
+	assert.WithinDuration(t, time.Now(), log.judgedTime(), time.Second)
+}
@@ -0,0 +1,75 @@
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+type entry_t[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// Cache is a fixed size least recently used cache, safe for concurrent use
+type Cache[K comparable, V any] struct {
+	size  int
+	items map[K]*list.Element
+	order *list.List
+	mutex sync.Mutex
+}
+
+func Create[K comparable, V any](size int) *Cache[K, V] {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Cache[K, V]{
+		size:  size,
+		items: make(map[K]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.order.MoveToFront(element)
+
+		return element.Value.(entry_t[K, V]).value, true
+	}
+
+	var zero V
+
+	return zero, false
+}
+
+// Add the value, evicting the least recently used one when the cache is full
+func (c *Cache[K, V]) Add(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		element.Value = entry_t[K, V]{key: key, value: value}
+		c.order.MoveToFront(element)
+
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(entry_t[K, V]).key)
+	}
+
+	c.items[key] = c.order.PushFront(entry_t[K, V]{key: key, value: value})
+}
+
+func (c *Cache[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,44 @@
+package lru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEviction(t *testing.T) {
+	c := Create[string, int](2)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	value, ok := c.Get("a")
+
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	c.Add("c", 3)
+
+	_, ok = c.Get("b")
+
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	value, ok = c.Get("c")
+
+	assert.True(t, ok)
+	assert.Equal(t, 3, value)
+}
+
+func TestUpdate(t *testing.T) {
+	c := Create[string, int](2)
+
+	c.Add("a", 1)
+	c.Add("a", 10)
+
+	value, ok := c.Get("a")
+
+	assert.True(t, ok)
+	assert.Equal(t, 10, value)
+	assert.Equal(t, 1, c.Len())
+}
@@ -0,0 +1,377 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	COUNTER   = "counter"
+	HISTOGRAM = "histogram"
+	// the label sets of a metric after which new ones are dropped, so a
+	// label like the ip can't eat all the memory
+	MAX_SERIES     = 10000
+	DROPPED_METRIC = "lfi_metrics_dropped_total"
+)
+
+// the buckets of the histograms without buckets, good for response sizes
+var DefaultBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+var nameRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+var labelRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Definition is a metric declared in the config, like
+// "counter lfi_requests_total labels method,status,route"
+type Definition struct {
+	Type string
+	Name string
+	Help string
+	// the fields of the logs used as labels
+	Labels []string
+	// the numeric field observed by histograms, and added by counters. counters
+	// without it count the logs
+	Field   string
+	Buckets []float64
+	// the quang query of the logs of the metric, every log when empty
+	When string
+}
+
+func parseBuckets(text string) ([]float64, error) {
+	buckets := make([]float64, 0)
+
+	for _, part := range strings.Split(text, ",") {
+		bucket, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket \"%s\"", part)
+		}
+
+		if len(buckets) > 0 && bucket <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("the buckets should be in increasing order")
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, nil
+}
+
+// the text after the field n of the fields of text, with its own spaces
+func afterField(text string, fields []string, n int) string {
+	end := 0
+
+	for _, field := range fields[:n+1] {
+		end += strings.Index(text[end:], field) + len(field)
+	}
+
+	return text[end:]
+}
+
+// "histogram lfi_response_size_bytes labels route field size buckets 100,1000 when status lt 500"
+func ParseDefinition(text string) (Definition, error) {
+	fields := strings.Fields(text)
+
+	if len(fields) < 2 {
+		return Definition{}, fmt.Errorf("invalid metric \"%s\". expected something like \"counter lfi_requests_total labels method,status\"", text)
+	}
+
+	d := Definition{Type: fields[0], Name: fields[1]}
+
+	if d.Type != COUNTER && d.Type != HISTOGRAM {
+		return Definition{}, fmt.Errorf("invalid metric type \"%s\". expected counter or histogram", d.Type)
+	}
+
+	if !nameRegex.MatchString(d.Name) {
+		return Definition{}, fmt.Errorf("invalid metric name \"%s\"", d.Name)
+	}
+
+	for i := 2; i < len(fields); i += 2 {
+		option := fields[i]
+
+		if option == "when" {
+			// the query is the rest of the line, with its own spaces
+			d.When = strings.TrimSpace(afterField(text, fields, i))
+
+			if len(d.When) == 0 {
+				return Definition{}, fmt.Errorf("missing query after when in metric %s", d.Name)
+			}
+
+			break
+		}
+
+		if i+1 >= len(fields) {
+			return Definition{}, fmt.Errorf("missing value of \"%s\" in metric %s", option, d.Name)
+		}
+
+		value := fields[i+1]
+
+		switch option {
+		case "labels":
+			for _, label := range strings.Split(value, ",") {
+				if !labelRegex.MatchString(label) {
+					return Definition{}, fmt.Errorf("invalid label \"%s\" in metric %s", label, d.Name)
+				}
+
+				d.Labels = append(d.Labels, label)
+			}
+		case "field":
+			d.Field = value
+		case "buckets":
+			buckets, err := parseBuckets(value)
+
+			if err != nil {
+				return Definition{}, fmt.Errorf("%s in metric %s", err.Error(), d.Name)
+			}
+
+			d.Buckets = buckets
+		default:
+			return Definition{}, fmt.Errorf("invalid option \"%s\" in metric %s. expected labels, field, buckets or when", option, d.Name)
+		}
+	}
+
+	if d.Type == HISTOGRAM && len(d.Field) == 0 {
+		return Definition{}, fmt.Errorf("the histogram %s needs a field, like \"field size\"", d.Name)
+	}
+
+	if d.Type == HISTOGRAM && len(d.Buckets) == 0 {
+		d.Buckets = DefaultBuckets
+	}
+
+	if d.Type == COUNTER && len(d.Buckets) > 0 {
+		return Definition{}, fmt.Errorf("the counter %s can't have buckets", d.Name)
+	}
+
+	d.Help = help(d)
+
+	return d, nil
+}
+
+// "the logs by method and status"
+func help(d Definition) string {
+	text := "the logs"
+
+	if len(d.Field) > 0 {
+		text = "the " + d.Field + " of the logs"
+	}
+
+	if len(d.Labels) > 0 {
+		text += " by " + strings.Join(d.Labels, ", ")
+	}
+
+	if len(d.When) > 0 {
+		text += " when " + d.When
+	}
+
+	return text
+}
+
+type series_t struct {
+	labels []string
+	value  float64
+	// the observations of each bucket, not cumulative
+	buckets []uint64
+	count   uint64
+}
+
+// Metric is a counter or a histogram, with a series for each label set
+type Metric struct {
+	Definition Definition
+
+	series  map[string]*series_t
+	dropped int
+	mutex   sync.Mutex
+}
+
+// Add counts value in the series of the label values: counters add it,
+// and histograms observe it
+func (m *Metric) Add(labels []string, value float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := strings.Join(labels, "\x00")
+	s, ok := m.series[key]
+
+	if !ok {
+		if len(m.series) >= MAX_SERIES {
+			m.dropped++
+			return
+		}
+
+		s = &series_t{labels: append([]string{}, labels...)}
+
+		if m.Definition.Type == HISTOGRAM {
+			s.buckets = make([]uint64, len(m.Definition.Buckets))
+		}
+
+		m.series[key] = s
+	}
+
+	s.value += value
+	s.count++
+
+	if m.Definition.Type != HISTOGRAM {
+		return
+	}
+
+	for i, bucket := range m.Definition.Buckets {
+		if value <= bucket {
+			s.buckets[i]++
+			break
+		}
+	}
+}
+
+// Dropped is how many observations were dropped because there were too many label sets
+func (m *Metric) Dropped() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.dropped
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// {method="GET",status="200"}, with the extra label pairs at the end
+func formatLabels(names []string, values []string, extra ...string) string {
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[i])))
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], labelEscaper.Replace(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// writes the metric in the prometheus text format
+func (m *Metric) write(w io.Writer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	d := m.Definition
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.Name, d.Help, d.Name, d.Type); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(m.series))
+
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	// counters without labels are always there, even before the first log
+	if len(keys) == 0 && d.Type == COUNTER && len(d.Labels) == 0 {
+		_, err := fmt.Fprintf(w, "%s 0\n", d.Name)
+		return err
+	}
+
+	for _, key := range keys {
+		s := m.series[key]
+
+		if d.Type == COUNTER {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", d.Name, formatLabels(d.Labels, s.labels), formatValue(s.value)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		cumulative := uint64(0)
+
+		for i, bucket := range d.Buckets {
+			cumulative += s.buckets[i]
+
+			fmt.Fprintf(w, "%s_bucket%s %d\n", d.Name, formatLabels(d.Labels, s.labels, "le", formatValue(bucket)), cumulative)
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d\n", d.Name, formatLabels(d.Labels, s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", d.Name, formatLabels(d.Labels, s.labels), formatValue(s.value))
+
+		if _, err := fmt.Fprintf(w, "%s_count%s %d\n", d.Name, formatLabels(d.Labels, s.labels), s.count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Registry is every metric exposed
+type Registry struct {
+	metrics []*Metric
+	names   map[string]bool
+}
+
+func CreateRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) Register(d Definition) (*Metric, error) {
+	if r.names[d.Name] {
+		return nil, fmt.Errorf("the metric %s is declared twice", d.Name)
+	}
+
+	if len(d.Help) == 0 {
+		d.Help = help(d)
+	}
+
+	r.names[d.Name] = true
+
+	m := &Metric{Definition: d, series: make(map[string]*series_t)}
+
+	r.metrics = append(r.metrics, m)
+
+	return m, nil
+}
+
+// Write writes every metric in the prometheus text format, and how many
+// observations were dropped by the metrics with too many label sets
+func (r *Registry) Write(w io.Writer) error {
+	dropped := make([]string, 0)
+
+	for _, m := range r.metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+
+		if n := m.Dropped(); n > 0 {
+			dropped = append(dropped, fmt.Sprintf("%s%s %d\n", DROPPED_METRIC, formatLabels([]string{"metric"}, []string{m.Definition.Name}), n))
+		}
+	}
+
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "# HELP %s the observations dropped because the metric has more than %d label sets\n# TYPE %s counter\n%s", DROPPED_METRIC, MAX_SERIES, DROPPED_METRIC, strings.Join(dropped, ""))
+
+	return err
+}
+
+// Handler serves the metrics, like at /metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		r.Write(w)
+	})
+}
@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDefinition(t *testing.T) {
+	d, err := ParseDefinition("histogram lfi_response_size_bytes labels route,method field size buckets 100,1000 when status lt 500 and method eq :get")
+
+	assert.Nil(t, err)
+	assert.Equal(t, HISTOGRAM, d.Type)
+	assert.Equal(t, "lfi_response_size_bytes", d.Name)
+	assert.Equal(t, []string{"route", "method"}, d.Labels)
+	assert.Equal(t, "size", d.Field)
+	assert.Equal(t, []float64{100, 1000}, d.Buckets)
+	assert.Equal(t, "status lt 500 and method eq :get", d.When)
+	assert.Equal(t, "the size of the logs by route, method when status lt 500 and method eq :get", d.Help)
+
+	d, err = ParseDefinition("counter lfi_requests_total")
+
+	assert.Nil(t, err)
+	assert.Equal(t, COUNTER, d.Type)
+	assert.Empty(t, d.Labels)
+	assert.Equal(t, "the logs", d.Help)
+
+	d, err = ParseDefinition("histogram lfi_latency field latency")
+
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultBuckets, d.Buckets)
+
+	// only the when option starts the query, not the other words "when"
+	d, err = ParseDefinition("counter when field when when\tpath eq 'a  when  b'")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "when", d.Field)
+	assert.Equal(t, "path eq 'a  when  b'", d.When)
+}
+
+func TestParseInvalidDefinition(t *testing.T) {
+	for _, text := range []string{
+		"counter",
+		"gauge lfi_requests",
+		"counter lfi-requests",
+		"counter lfi_requests labels",
+		"counter lfi_requests labels route,bad-label",
+		"counter lfi_requests buckets 1,2",
+		"counter lfi_requests color red",
+		"histogram lfi_sizes",
+		"histogram lfi_sizes field size buckets 10,5",
+		"histogram lfi_sizes field size buckets 10,ten",
+	} {
+		_, err := ParseDefinition(text)
+
+		assert.NotNil(t, err, text)
+	}
+
+	for _, text := range []string{"counter lfi_requests when", "counter lfi_requests labels route when  "} {
+		_, err := ParseDefinition(text)
+
+		if assert.NotNil(t, err, text) {
+			assert.Equal(t, "missing query after when in metric lfi_requests", err.Error())
+		}
+	}
+}
+
+func write(r *Registry) string {
+	var b bytes.Buffer
+
+	r.Write(&b)
+
+	return b.String()
+}
+
+func TestCounter(t *testing.T) {
+	r := CreateRegistry()
+	d, _ := ParseDefinition("counter lfi_requests_total labels method,status")
+	m, err := r.Register(d)
+
+	assert.Nil(t, err)
+
+	m.Add([]string{"GET", "200"}, 1)
+	m.Add([]string{"POST", "500"}, 1)
+	m.Add([]string{"GET", "200"}, 1)
+
+	assert.Equal(t, `# HELP lfi_requests_total the logs by method, status
+# TYPE lfi_requests_total counter
+lfi_requests_total{method="GET",status="200"} 2
+lfi_requests_total{method="POST",status="500"} 1
+`, write(r))
+}
+
+func TestCounterWithoutLogs(t *testing.T) {
+	r := CreateRegistry()
+	d, _ := ParseDefinition("counter lfi_lines_total")
+
+	r.Register(d)
+
+	assert.Contains(t, write(r), "\nlfi_lines_total 0\n")
+}
+
+func TestHistogram(t *testing.T) {
+	r := CreateRegistry()
+	d, _ := ParseDefinition("histogram lfi_size_bytes labels route field size buckets 100,1000")
+	m, _ := r.Register(d)
+
+	m.Add([]string{"/users"}, 50)
+	m.Add([]string{"/users"}, 500)
+	m.Add([]string{"/users"}, 100)
+	m.Add([]string{"/users"}, 5000)
+
+	assert.Equal(t, `# HELP lfi_size_bytes the size of the logs by route
+# TYPE lfi_size_bytes histogram
+lfi_size_bytes_bucket{route="/users",le="100"} 2
+lfi_size_bytes_bucket{route="/users",le="1000"} 3
+lfi_size_bytes_bucket{route="/users",le="+Inf"} 4
+lfi_size_bytes_sum{route="/users"} 5650
+lfi_size_bytes_count{route="/users"} 4
+`, write(r))
+}
+
+func TestEscaping(t *testing.T) {
+	r := CreateRegistry()
+	d, _ := ParseDefinition("counter lfi_requests_total labels agent")
+	m, _ := r.Register(d)
+
+	m.Add([]string{"say \"hi\"\\\n"}, 1)
+
+	assert.Contains(t, write(r), `lfi_requests_total{agent="say \"hi\"\\\n"} 1`)
+}
+
+func TestDuplicateName(t *testing.T) {
+	r := CreateRegistry()
+	d, _ := ParseDefinition("counter lfi_requests_total")
+
+	_, err := r.Register(d)
+	assert.Nil(t, err)
+
+	_, err = r.Register(d)
+	assert.NotNil(t, err)
+}
+
+func TestMaxSeries(t *testing.T) {
+	r := CreateRegistry()
+	d, _ := ParseDefinition("counter lfi_requests_total labels ip")
+	m, _ := r.Register(d)
+
+	for i := range MAX_SERIES + 10 {
+		m.Add([]string{fmt.Sprint(i)}, 1)
+	}
+
+	m.Add([]string{"0"}, 1)
+
+	assert.Equal(t, 10, m.Dropped())
+
+	text := write(r)
+
+	assert.Contains(t, text, `lfi_requests_total{ip="0"} 2`)
+	assert.NotContains(t, text, fmt.Sprintf(`{ip="%d"}`, MAX_SERIES))
+	assert.Contains(t, text, `lfi_metrics_dropped_total{metric="lfi_requests_total"} 10`)
+}
+
+func TestHandler(t *testing.T) {
+	r := CreateRegistry()
+	d, _ := ParseDefinition("counter lfi_requests_total labels method")
+	m, _ := r.Register(d)
+
+	m.Add([]string{"GET"}, 1)
+
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL + "/metrics")
+
+	assert.Nil(t, err)
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+
+	assert.Equal(t, 200, response.StatusCode)
+	assert.True(t, strings.HasPrefix(response.Header.Get("Content-Type"), "text/plain; version=0.0.4"))
+	assert.Contains(t, string(body), `lfi_requests_total{method="GET"} 1`)
+}
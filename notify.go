@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/marcos-venicius/lfi/alert"
+	"github.com/marcos-venicius/lfi/otlp"
+	"github.com/marcos-venicius/lfi/webhook"
+)
+
+func createWebhook(url string, format string, spool string) (*webhook.Sink, error) {
+	return webhook.Create(url, webhook.Options{
+		Format:  format,
+		Retries: webhook.DEFAULT_RETRIES,
+		Spool:   spool,
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "error: webhook: %s\n", err.Error())
+		},
+	})
+}
+
+// the time of the log in RFC3339, or as it is when it's not valid
+func eventTime(log log_t) string {
+	if !log.at.IsZero() {
+		return log.at.Format(time.RFC3339)
+	}
+
+	return log.time
+}
+
+func logEvent(tokens []string, t *template.Template, log log_t) webhook.Event {
+	return webhook.Event{
+		Kind:   "log",
+		Time:   eventTime(log),
+		Text:   formatLine(tokens, t, log),
+		Fields: log.values(),
+	}
+}
+
+func alertEvent(rule alert.Rule, key string, t time.Time, line string) webhook.Event {
+	fields := map[string]any{
+		"alert":     rule.Name,
+		"query":     rule.Query,
+		"threshold": rule.Threshold,
+		"window":    displayDuration(rule.Window),
+	}
+
+	if len(rule.Per) > 0 {
+		fields[rule.Per] = key
+	}
+
+	return webhook.Event{
+		Kind:   "alert",
+		Time:   t.Format(time.RFC3339),
+		Text:   line,
+		Fields: fields,
+	}
+}
+
+func createOTLPExporter(endpoint string, headers string, service string) (*otlp.Exporter, error) {
+	parsed, err := otlp.ParseHeaders(headers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return otlp.Create(endpoint, otlp.Options{
+		ServiceName: service,
+		Headers:     parsed,
+		Retries:     otlp.DEFAULT_RETRIES,
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "error: otlp: %s\n", err.Error())
+		},
+	})
+}
+
+// 5xx are errors and 4xx warnings
+func logSeverity(log log_t) int {
+	switch {
+	case log.statusCode >= 500:
+		return otlp.SEVERITY_ERROR
+	case log.statusCode >= 400:
+		return otlp.SEVERITY_WARN
+	}
+
+	return otlp.SEVERITY_INFO
+}
+
+// the log as an otlp record, with the attributes of the http semantic
+// conventions, and the named groups of the config regex as they are
+func logRecord(tokens []string, t *template.Template, log log_t) otlp.Record {
+	attributes := map[string]any{
+		"http.request.method":       methodDisplay(log.method),
+		"http.response.status_code": int64(log.statusCode),
+		"http.response.body.size":   int64(log.size),
+		"url.path":                  log.path,
+		"client.address":            log.ip,
+		"network.protocol.version":  strings.TrimPrefix(log.version, "HTTP/"),
+	}
+
+	// "-" is what the logs have when there is no value
+	optional := map[string]string{
+		"url.query":           log.query,
+		"http.route":          log.route,
+		"server.address":      log.host,
+		"user_agent.original": log.userAgent,
+	}
+
+	for key, value := range optional {
+		if len(value) > 0 && value != "-" {
+			attributes[key] = value
+		}
+	}
+
+	for name, value := range log.custom {
+		attributes[name] = value
+	}
+
+	// the record is still sent without the time, with the time it was read
+	return otlp.Record{
+		Time:       log.at,
+		Severity:   logSeverity(log),
+		Body:       formatLine(tokens, t, log),
+		Attributes: attributes,
+	}
+}
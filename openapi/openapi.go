@@ -0,0 +1,268 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Status int
+
+const (
+	Documented Status = iota
+	UndocumentedPath
+	UndocumentedMethod
+)
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+type Operation struct {
+	ID     string
+	Method string
+	// the path template as declared in the spec, including the server base path
+	Route string
+}
+
+type segment_t struct {
+	literal string
+	// only set when the segment has variables, like "{id}" or "{name}.json"
+	pattern *regexp.Regexp
+}
+
+type path_t struct {
+	route      string
+	segments   []segment_t
+	literals   int
+	operations map[string]Operation
+}
+
+type Spec struct {
+	paths []path_t
+}
+
+type document_t struct {
+	BasePath string `yaml:"basePath"`
+	Servers  []struct {
+		URL string `yaml:"url"`
+	} `yaml:"servers"`
+	Paths map[string]map[string]any `yaml:"paths"`
+}
+
+func (s Status) String() string {
+	switch s {
+	case UndocumentedPath:
+		return "path"
+	case UndocumentedMethod:
+		return "method"
+	}
+
+	return ""
+}
+
+func splitPath(path string) []string {
+	segments := make([]string, 0)
+
+	for _, segment := range strings.Split(path, "/") {
+		if len(segment) > 0 {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments
+}
+
+var variableRegex = regexp.MustCompile(`\{[^}/]+\}`)
+var escapedVariableRegex = regexp.MustCompile(`__([^_/]+)__`)
+
+func parseSegment(segment string) segment_t {
+	if !strings.Contains(segment, "{") {
+		return segment_t{literal: segment}
+	}
+
+	pattern := "^"
+	last := 0
+
+	for _, match := range variableRegex.FindAllStringIndex(segment, -1) {
+		pattern += regexp.QuoteMeta(segment[last:match[0]]) + "[^/]+?"
+		last = match[1]
+	}
+
+	pattern += regexp.QuoteMeta(segment[last:]) + "$"
+
+	return segment_t{pattern: regexp.MustCompile(pattern)}
+}
+
+// the path of every server url, like "/v1" for "https://api.example.com/v1"
+func basePaths(document document_t) []string {
+	bases := make([]string, 0)
+
+	if len(document.BasePath) > 0 {
+		bases = append(bases, strings.TrimSuffix(document.BasePath, "/"))
+	}
+
+	for _, server := range document.Servers {
+		// server urls can have variables too, like "https://{host}/v1"
+		raw := variableRegex.ReplaceAllStringFunc(server.URL, func(v string) string {
+			return "__" + v[1:len(v)-1] + "__"
+		})
+
+		parsed, err := url.Parse(raw)
+
+		if err != nil {
+			continue
+		}
+
+		base := strings.TrimSuffix(parsed.Path, "/")
+		base = escapedVariableRegex.ReplaceAllString(base, "{$1}")
+
+		bases = append(bases, base)
+	}
+
+	if len(bases) == 0 {
+		bases = append(bases, "")
+	}
+
+	return bases
+}
+
+// Parse an OpenAPI 3 or Swagger 2 document, in yaml or json
+func Parse(content []byte) (*Spec, error) {
+	var document document_t
+
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	if len(document.Paths) == 0 {
+		return nil, errors.New("the spec has no paths")
+	}
+
+	spec := Spec{paths: make([]path_t, 0)}
+
+	for _, base := range basePaths(document) {
+		for template, item := range document.Paths {
+			route := base + template
+
+			path := path_t{
+				route:      route,
+				segments:   make([]segment_t, 0),
+				operations: make(map[string]Operation),
+			}
+
+			for _, segment := range splitPath(route) {
+				s := parseSegment(segment)
+
+				if s.pattern == nil {
+					path.literals++
+				}
+
+				path.segments = append(path.segments, s)
+			}
+
+			for _, method := range methods {
+				operation, ok := item[method]
+
+				if !ok {
+					continue
+				}
+
+				id := ""
+
+				if fields, ok := operation.(map[string]any); ok {
+					if value, ok := fields["operationId"].(string); ok {
+						id = value
+					}
+				}
+
+				path.operations[method] = Operation{
+					ID:     id,
+					Method: strings.ToUpper(method),
+					Route:  route,
+				}
+			}
+
+			spec.paths = append(spec.paths, path)
+		}
+	}
+
+	// concrete paths like "/users/me" should win over "/users/{id}"
+	sort.SliceStable(spec.paths, func(i, j int) bool {
+		if spec.paths[i].literals != spec.paths[j].literals {
+			return spec.paths[i].literals > spec.paths[j].literals
+		}
+
+		return spec.paths[i].route < spec.paths[j].route
+	})
+
+	return &spec, nil
+}
+
+func Load(filePath string) (*Spec, error) {
+	content, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := Parse(content)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err.Error())
+	}
+
+	return spec, nil
+}
+
+func (p path_t) match(segments []string) bool {
+	if len(segments) != len(p.segments) {
+		return false
+	}
+
+	for i, segment := range p.segments {
+		if segment.pattern == nil {
+			if segment.literal != segments[i] {
+				return false
+			}
+		} else if !segment.pattern.MatchString(segments[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Match finds the operation declared for the method and path.
+// when the path is declared but not the method, the returned operation
+// still has the route of the path.
+func (s Spec) Match(method, path string) (Operation, Status) {
+	segments := splitPath(path)
+	method = strings.ToLower(method)
+
+	var found *path_t
+
+	for i := range s.paths {
+		if !s.paths[i].match(segments) {
+			continue
+		}
+
+		if operation, ok := s.paths[i].operations[method]; ok {
+			return operation, Documented
+		}
+
+		if found == nil {
+			found = &s.paths[i]
+		}
+	}
+
+	if found != nil {
+		return Operation{Method: strings.ToUpper(method), Route: found.route}, UndocumentedMethod
+	}
+
+	return Operation{Method: strings.ToUpper(method)}, UndocumentedPath
+}
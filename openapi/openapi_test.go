@@ -0,0 +1,108 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlSpec = `
+openapi: 3.0.0
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+    get:
+      operationId: getUser
+    delete:
+      operationId: deleteUser
+  /users/me:
+    get:
+      operationId: getMe
+  /files/{name}.json:
+    get:
+      operationId: getFile
+`
+
+const jsonSpec = `{
+  "swagger": "2.0",
+  "basePath": "/api",
+  "paths": {
+    "/orders": {
+      "post": { "operationId": "createOrder" }
+    }
+  }
+}`
+
+func TestMatchYaml(t *testing.T) {
+	spec, err := Parse([]byte(yamlSpec))
+
+	assert.Nil(t, err)
+
+	operation, status := spec.Match("GET", "/v1/users/42")
+
+	assert.Equal(t, Documented, status)
+	assert.Equal(t, "getUser", operation.ID)
+	assert.Equal(t, "/v1/users/{id}", operation.Route)
+
+	operation, status = spec.Match("GET", "/v1/users/me")
+
+	assert.Equal(t, Documented, status)
+	assert.Equal(t, "getMe", operation.ID)
+
+	operation, status = spec.Match("DELETE", "/v1/users/me")
+
+	assert.Equal(t, Documented, status)
+	assert.Equal(t, "deleteUser", operation.ID)
+
+	operation, status = spec.Match("GET", "/v1/files/report.json")
+
+	assert.Equal(t, Documented, status)
+	assert.Equal(t, "getFile", operation.ID)
+
+	operation, status = spec.Match("PUT", "/v1/users/42")
+
+	assert.Equal(t, UndocumentedMethod, status)
+	assert.Equal(t, "", operation.ID)
+	assert.Equal(t, "/v1/users/{id}", operation.Route)
+
+	_, status = spec.Match("GET", "/v1/.env")
+
+	assert.Equal(t, UndocumentedPath, status)
+
+	_, status = spec.Match("GET", "/users/42")
+
+	assert.Equal(t, UndocumentedPath, status)
+}
+
+func TestMatchJson(t *testing.T) {
+	spec, err := Parse([]byte(jsonSpec))
+
+	assert.Nil(t, err)
+
+	operation, status := spec.Match("POST", "/api/orders")
+
+	assert.Equal(t, Documented, status)
+	assert.Equal(t, "createOrder", operation.ID)
+	assert.Equal(t, "POST", operation.Method)
+}
+
+func TestParseInvalidSpec(t *testing.T) {
+	_, err := Parse([]byte("openapi: 3.0.0"))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "the spec has no paths", err.Error())
+
+	_, err = Parse([]byte("paths: ["))
+
+	assert.NotNil(t, err)
+}
+
+func TestStatusString(t *testing.T) {
+	assert.Equal(t, "", Documented.String())
+	assert.Equal(t, "path", UndocumentedPath.String())
+	assert.Equal(t, "method", UndocumentedMethod.String())
+}
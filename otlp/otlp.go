@@ -0,0 +1,307 @@
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcos-venicius/lfi/sender"
+)
+
+const (
+	DEFAULT_BATCH_SIZE   = 512
+	DEFAULT_RETRIES      = sender.DEFAULT_RETRIES
+	DEFAULT_SERVICE_NAME = "lfi"
+	// the path added to the endpoints without one, like http://localhost:4318
+	LOGS_PATH = "/v1/logs"
+)
+
+// the severity numbers of the otlp log data model
+const (
+	SEVERITY_INFO  = 9
+	SEVERITY_WARN  = 13
+	SEVERITY_ERROR = 17
+)
+
+var severityTexts = map[int]string{
+	SEVERITY_INFO:  "INFO",
+	SEVERITY_WARN:  "WARN",
+	SEVERITY_ERROR: "ERROR",
+}
+
+// Record is a log record, with attributes like http.request.method
+type Record struct {
+	// when the request happened, the time the record was read when zero
+	Time     time.Time
+	Severity int
+	Body     string
+	// strings, integers, floats, bools or slices of them
+	Attributes map[string]any
+}
+
+type Options struct {
+	// the service.name of the resource
+	ServiceName string
+	// extra headers of the requests, like an authorization
+	Headers       map[string]string
+	BatchSize     int
+	FlushInterval time.Duration
+	// how many times a batch is sent again, waiting Backoff, 2 * Backoff, 4 * Backoff...
+	Retries int
+	Backoff time.Duration
+	Timeout time.Duration
+	// called with the errors of the deliveries, which happen in background
+	OnError func(error)
+}
+
+// Exporter delivers log records to an otlp/http endpoint, in batches, from background
+type Exporter struct {
+	options Options
+	sender  *sender.Sender[pending_t]
+}
+
+type pending_t struct {
+	record   Record
+	observed time.Time
+}
+
+// "key=value,other=value", like OTEL_EXPORTER_OTLP_HEADERS
+func ParseHeaders(text string) (map[string]string, error) {
+	headers := make(map[string]string)
+
+	if len(strings.TrimSpace(text)) == 0 {
+		return headers, nil
+	}
+
+	for _, pair := range strings.Split(text, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+
+		if !ok || len(key) == 0 {
+			return nil, fmt.Errorf("invalid header \"%s\". expected something like \"authorization=Bearer token\"", pair)
+		}
+
+		decoded, err := url.QueryUnescape(strings.TrimSpace(value))
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid header \"%s\": %s", pair, err.Error())
+		}
+
+		headers[key] = decoded
+	}
+
+	return headers, nil
+}
+
+// the logs url of the endpoint. endpoints without a path get /v1/logs
+func logsURL(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+		return "", fmt.Errorf("invalid otlp endpoint \"%s\". expected something like \"http://localhost:4318\"", endpoint)
+	}
+
+	if len(strings.Trim(parsed.Path, "/")) == 0 {
+		parsed.Path = LOGS_PATH
+	}
+
+	return parsed.String(), nil
+}
+
+func Create(endpoint string, options Options) (*Exporter, error) {
+	logs, err := logsURL(endpoint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(options.ServiceName) == 0 {
+		options.ServiceName = DEFAULT_SERVICE_NAME
+	}
+
+	if options.BatchSize < 1 {
+		options.BatchSize = DEFAULT_BATCH_SIZE
+	}
+
+	if options.OnError == nil {
+		options.OnError = func(error) {}
+	}
+
+	e := &Exporter{options: options}
+
+	e.sender = sender.Create(sender.CreateEndpoint(logs, "otlp endpoint", options.Headers, options.Timeout, retryable), sender.Options[pending_t]{
+		BatchSize:     options.BatchSize,
+		FlushInterval: options.FlushInterval,
+		Retries:       options.Retries,
+		Backoff:       options.Backoff,
+		Encode:        e.body,
+		Failed: func(_ []byte, records int, _ bool, err error) {
+			options.OnError(fmt.Errorf("%s, %d records were not sent", err.Error(), records))
+		},
+		OnError: options.OnError,
+	})
+
+	return e, nil
+}
+
+// Send queues the record without blocking. when the queue is full the
+// record is dropped
+func (e *Exporter) Send(record Record) {
+	e.sender.Send(pending_t{record: record, observed: time.Now()})
+}
+
+// how many records were dropped because the queue was full
+func (e *Exporter) Dropped() int {
+	return e.sender.Dropped()
+}
+
+// Close sends the queued records and waits for them
+func (e *Exporter) Close() {
+	e.sender.Close()
+}
+
+// the otlp json encoding, where the 64 bits integers are strings
+type any_value_t struct {
+	StringValue *string        `json:"stringValue,omitempty"`
+	IntValue    *string        `json:"intValue,omitempty"`
+	DoubleValue *float64       `json:"doubleValue,omitempty"`
+	BoolValue   *bool          `json:"boolValue,omitempty"`
+	ArrayValue  *array_value_t `json:"arrayValue,omitempty"`
+}
+
+type array_value_t struct {
+	Values []any_value_t `json:"values"`
+}
+
+type key_value_t struct {
+	Key   string      `json:"key"`
+	Value any_value_t `json:"value"`
+}
+
+type log_record_t struct {
+	TimeUnixNano         string        `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string        `json:"observedTimeUnixNano"`
+	SeverityNumber       int           `json:"severityNumber,omitempty"`
+	SeverityText         string        `json:"severityText,omitempty"`
+	Body                 any_value_t   `json:"body"`
+	Attributes           []key_value_t `json:"attributes,omitempty"`
+}
+
+type scope_logs_t struct {
+	Scope      map[string]string `json:"scope"`
+	LogRecords []log_record_t    `json:"logRecords"`
+}
+
+type resource_logs_t struct {
+	Resource  map[string][]key_value_t `json:"resource"`
+	ScopeLogs []scope_logs_t           `json:"scopeLogs"`
+}
+
+type request_t struct {
+	ResourceLogs []resource_logs_t `json:"resourceLogs"`
+}
+
+func stringValue(text string) any_value_t {
+	return any_value_t{StringValue: &text}
+}
+
+// the value of an attribute, by its kind, so named types like
+// quang.IntegerType are integers too
+func anyValue(value any) any_value_t {
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.String:
+		return stringValue(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		text := strconv.FormatInt(v.Int(), 10)
+		return any_value_t{IntValue: &text}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		text := strconv.FormatUint(v.Uint(), 10)
+		return any_value_t{IntValue: &text}
+	case reflect.Float32, reflect.Float64:
+		number := v.Float()
+		return any_value_t{DoubleValue: &number}
+	case reflect.Bool:
+		boolean := v.Bool()
+		return any_value_t{BoolValue: &boolean}
+	case reflect.Slice, reflect.Array:
+		values := make([]any_value_t, 0, v.Len())
+
+		for i := range v.Len() {
+			values = append(values, anyValue(v.Index(i).Interface()))
+		}
+
+		return any_value_t{ArrayValue: &array_value_t{Values: values}}
+	case reflect.Invalid:
+		return stringValue("")
+	}
+
+	return stringValue(fmt.Sprint(value))
+}
+
+// the attributes sorted by key
+func keyValues(attributes map[string]any) []key_value_t {
+	keys := make([]string, 0, len(attributes))
+
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]key_value_t, 0, len(keys))
+
+	for _, key := range keys {
+		pairs = append(pairs, key_value_t{Key: key, Value: anyValue(attributes[key])})
+	}
+
+	return pairs
+}
+
+func unixNano(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func (e *Exporter) body(batch []pending_t) ([]byte, error) {
+	records := make([]log_record_t, 0, len(batch))
+
+	for _, pending := range batch {
+		records = append(records, log_record_t{
+			TimeUnixNano:         unixNano(pending.record.Time),
+			ObservedTimeUnixNano: unixNano(pending.observed),
+			SeverityNumber:       pending.record.Severity,
+			SeverityText:         severityTexts[pending.record.Severity],
+			Body:                 stringValue(pending.record.Body),
+			Attributes:           keyValues(pending.record.Attributes),
+		})
+	}
+
+	return json.Marshal(request_t{
+		ResourceLogs: []resource_logs_t{{
+			Resource: map[string][]key_value_t{
+				"attributes": keyValues(map[string]any{"service.name": e.options.ServiceName}),
+			},
+			ScopeLogs: []scope_logs_t{{
+				Scope:      map[string]string{"name": "lfi"},
+				LogRecords: records,
+			}},
+		}},
+	})
+}
+
+// the otlp answers that are worth sending the batch again, besides the
+// network errors
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
@@ -0,0 +1,174 @@
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/marcos-venicius/lfi/sender/sendertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// the requests received, decoded
+func requests(t *testing.T, r *sendertest.Receiver) []request_t {
+	requests := make([]request_t, 0)
+
+	for _, body := range r.Bodies() {
+		decoded := request_t{}
+
+		assert.Nil(t, json.Unmarshal(body, &decoded))
+
+		requests = append(requests, decoded)
+	}
+
+	return requests
+}
+
+func records(t *testing.T, r *sendertest.Receiver) []log_record_t {
+	records := make([]log_record_t, 0)
+
+	for _, request := range requests(t, r) {
+		for _, resource := range request.ResourceLogs {
+			for _, scope := range resource.ScopeLogs {
+				records = append(records, scope.LogRecords...)
+			}
+		}
+	}
+
+	return records
+}
+
+func record(body string) Record {
+	return Record{Time: time.Date(2024, 10, 10, 13, 0, 0, 0, time.UTC), Severity: SEVERITY_INFO, Body: body}
+}
+
+func attribute(record log_record_t, key string) *any_value_t {
+	for _, pair := range record.Attributes {
+		if pair.Key == key {
+			return &pair.Value
+		}
+	}
+
+	return nil
+}
+
+func TestRecords(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	e, err := Create(r.URL(), Options{ServiceName: "gateway", Headers: map[string]string{"Authorization": "Bearer token"}})
+
+	assert.Nil(t, err)
+
+	e.Send(Record{
+		Time:     time.Date(2024, 10, 10, 13, 0, 0, 0, time.UTC),
+		Severity: SEVERITY_ERROR,
+		Body:     "GET /users 500",
+		Attributes: map[string]any{
+			"http.request.method":       "GET",
+			"http.response.status_code": int64(500),
+			"url.path":                  "/users",
+			"client.address":            "10.0.0.1",
+			"ratio":                     0.5,
+			"documented":                true,
+			"undocumented":              []string{"path"},
+		},
+	})
+	e.Close()
+
+	assert.Equal(t, []string{LOGS_PATH}, r.Paths())
+	assert.Equal(t, "application/json", r.Headers()[0].Get("Content-Type"))
+	assert.Equal(t, "Bearer token", r.Headers()[0].Get("Authorization"))
+
+	resource := requests(t, r)[0].ResourceLogs[0].Resource["attributes"]
+
+	assert.Equal(t, "service.name", resource[0].Key)
+	assert.Equal(t, "gateway", *resource[0].Value.StringValue)
+
+	received := records(t, r)
+
+	assert.Equal(t, 1, len(received))
+	assert.Equal(t, "1728565200000000000", received[0].TimeUnixNano)
+	assert.NotEmpty(t, received[0].ObservedTimeUnixNano)
+	assert.Equal(t, SEVERITY_ERROR, received[0].SeverityNumber)
+	assert.Equal(t, "ERROR", received[0].SeverityText)
+	assert.Equal(t, "GET /users 500", *received[0].Body.StringValue)
+	assert.Equal(t, "GET", *attribute(received[0], "http.request.method").StringValue)
+	assert.Equal(t, "500", *attribute(received[0], "http.response.status_code").IntValue)
+	assert.Equal(t, "/users", *attribute(received[0], "url.path").StringValue)
+	assert.Equal(t, "10.0.0.1", *attribute(received[0], "client.address").StringValue)
+	assert.Equal(t, 0.5, *attribute(received[0], "ratio").DoubleValue)
+	assert.True(t, *attribute(received[0], "documented").BoolValue)
+	assert.Equal(t, "path", *attribute(received[0], "undocumented").ArrayValue.Values[0].StringValue)
+}
+
+func TestNamedTypes(t *testing.T) {
+	type integer_t int64
+
+	value := anyValue(integer_t(404))
+
+	assert.Nil(t, value.StringValue)
+	assert.Equal(t, "404", *value.IntValue)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	r.Fail(http.StatusServiceUnavailable, 2)
+
+	errors := []error{}
+	e, _ := Create(r.URL(), Options{BatchSize: 1, Retries: 3, Backoff: time.Millisecond, OnError: func(err error) { errors = append(errors, err) }})
+
+	e.Send(record("a"))
+
+	assert.Eventually(t, func() bool { return len(r.Bodies()) == 1 }, time.Second, 5*time.Millisecond)
+
+	r.Fail(http.StatusInternalServerError, -1)
+
+	e.Send(record("b"))
+	e.Close()
+
+	// 503 is retried, but not 500
+	assert.Equal(t, 1, len(records(t, r)))
+	assert.Equal(t, "a", *records(t, r)[0].Body.StringValue)
+	assert.Equal(t, 4, r.Requests())
+	assert.Equal(t, 1, len(errors))
+	assert.Equal(t, "otlp endpoint answered 500 Internal Server Error, 1 records were not sent", errors[0].Error())
+}
+
+func TestLogsURL(t *testing.T) {
+	for endpoint, expected := range map[string]string{
+		"http://localhost:4318":            "http://localhost:4318/v1/logs",
+		"http://localhost:4318/":           "http://localhost:4318/v1/logs",
+		"https://collector/custom/v1/logs": "https://collector/custom/v1/logs",
+	} {
+		logs, err := logsURL(endpoint)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, logs)
+	}
+
+	for _, endpoint := range []string{"localhost:4318", "ftp://localhost", "http://"} {
+		_, err := logsURL(endpoint)
+
+		assert.NotNil(t, err, endpoint)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("authorization=Bearer%20token, x-scope = team")
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"authorization": "Bearer token", "x-scope": "team"}, headers)
+
+	headers, err = ParseHeaders("")
+
+	assert.Nil(t, err)
+	assert.Empty(t, headers)
+
+	_, err = ParseHeaders("authorization")
+
+	assert.NotNil(t, err)
+}
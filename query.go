@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type query_token_kind_t int
+
+const (
+	qt_symbol query_token_kind_t = iota
+	qt_string
+	qt_other
+)
+
+type query_token_t struct {
+	kind  query_token_kind_t
+	value string
+}
+
+// quang does not expose the expression it parsed, so this is a small scanner
+// that follows the same lexical rules just to find out which variables and
+// patterns the user referenced.
+// it assumes the query was already accepted by quang.Init.
+func scanQuery(query string) []query_token_t {
+	tokens := make([]query_token_t, 0)
+
+	cursor := 0
+
+	for cursor < len(query) {
+		c := query[cursor]
+
+		switch {
+		case c == '\'':
+			bytes := make([]byte, 0)
+
+			cursor++
+
+			for cursor < len(query) && query[cursor] != '\'' {
+				if query[cursor] == '\\' && cursor+1 < len(query) {
+					cursor++
+				}
+
+				bytes = append(bytes, query[cursor])
+				cursor++
+			}
+
+			cursor++
+
+			tokens = append(tokens, query_token_t{kind: qt_string, value: string(bytes)})
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			bot := cursor
+
+			for cursor < len(query) && (query[cursor] == '_' || (query[cursor] >= 'a' && query[cursor] <= 'z') || (query[cursor] >= 'A' && query[cursor] <= 'Z')) {
+				cursor++
+			}
+
+			tokens = append(tokens, query_token_t{kind: qt_symbol, value: query[bot:cursor]})
+		case c == ' ' || c == '\t' || c == '\n':
+			cursor++
+		default:
+			tokens = append(tokens, query_token_t{kind: qt_other, value: string(c)})
+			cursor++
+		}
+	}
+
+	return tokens
+}
+
+// extract every "<variable> reg '<pattern>'" comparison of the query
+func queryRegexPatterns(query string) (map[string][]*regexp.Regexp, error) {
+	patterns := make(map[string][]*regexp.Regexp)
+	tokens := scanQuery(query)
+
+	for i := 0; i+2 < len(tokens); i++ {
+		variable, operator, pattern := tokens[i], tokens[i+1], tokens[i+2]
+
+		if variable.kind != qt_symbol || operator.kind != qt_symbol || operator.value != "reg" || pattern.kind != qt_string {
+			continue
+		}
+
+		re, err := regexp.Compile(pattern.value)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s' for %s: %s", pattern.value, variable.value, err.Error())
+		}
+
+		patterns[variable.value] = append(patterns[variable.value], re)
+	}
+
+	return patterns, nil
+}
+
+// quang compiles the patterns of reg while evaluating and panics on the
+// invalid ones, so every reg is checked when the query is compiled. the
+// pattern has to be a string, a variable would take it from the logs
+func validateQueryPatterns(query string) error {
+	tokens := scanQuery(query)
+
+	for i, token := range tokens {
+		if i == 0 || token.kind != qt_symbol || token.value != "reg" {
+			continue
+		}
+
+		if i+1 >= len(tokens) || tokens[i+1].kind != qt_string {
+			return fmt.Errorf("the pattern of reg should be a string, like resource reg '^/api'")
+		}
+
+		if _, err := regexp.Compile(tokens[i+1].value); err != nil {
+			return fmt.Errorf("invalid pattern '%s': %s", tokens[i+1].value, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanQuery(t *testing.T) {
+	tokens := scanQuery(`path reg '^/api' and (status gte 500 or agent eq 'it\'s')`)
+
+	assert.Equal(t, []query_token_t{
+		{kind: qt_symbol, value: "path"},
+		{kind: qt_symbol, value: "reg"},
+		{kind: qt_string, value: "^/api"},
+		{kind: qt_symbol, value: "and"},
+		{kind: qt_other, value: "("},
+		{kind: qt_symbol, value: "status"},
+		{kind: qt_symbol, value: "gte"},
+		{kind: qt_other, value: "5"},
+		{kind: qt_other, value: "0"},
+		{kind: qt_other, value: "0"},
+		{kind: qt_symbol, value: "or"},
+		{kind: qt_symbol, value: "agent"},
+		{kind: qt_symbol, value: "eq"},
+		{kind: qt_string, value: "it's"},
+		{kind: qt_other, value: ")"},
+	}, tokens)
+}
+
+func TestQueryRegexPatterns(t *testing.T) {
+	cases := map[string]map[string][]string{
+		"":                                  {},
+		"status eq 200":                     {},
+		"path eq 'reg'":                     {},
+		"path reg '^/api'":                  {"path": {"^/api"}},
+		"path reg 'a' or path reg 'b'":      {"path": {"a", "b"}},
+		"agent reg 'curl' and ip reg '^10'": {"agent": {"curl"}, "ip": {"^10"}},
+	}
+
+	for query, expected := range cases {
+		patterns, err := queryRegexPatterns(query)
+
+		assert.Nil(t, err, query)
+
+		found := make(map[string][]string)
+
+		for variable, regexes := range patterns {
+			for _, re := range regexes {
+				found[variable] = append(found[variable], re.String())
+			}
+		}
+
+		assert.Equal(t, expected, found, query)
+	}
+}
+
+func TestQueryRegexPatternsInvalid(t *testing.T) {
+	_, err := queryRegexPatterns("resource reg '('")
+
+	assert.NotNil(t, err)
+}
+
+func TestCompileQueryPatterns(t *testing.T) {
+	for _, query := range []string{"resource reg '^/api/(users|orders)'", "status eq 404 and agent reg 'curl' or path reg '[.]php$'"} {
+		_, err := compileQuery(query)
+
+		assert.Nil(t, err, query)
+	}
+
+	errors := map[string]string{
+		"resource reg '('":                 "invalid pattern '(': error parsing regexp: missing closing ): `(`",
+		"status eq 404 and agent reg '[a'": "invalid pattern '[a': error parsing regexp: missing closing ]: `[a`",
+		"resource reg agent":               "the pattern of reg should be a string, like resource reg '^/api'",
+	}
+
+	for query, expected := range errors {
+		_, err := compileQuery(query)
+
+		if assert.NotNil(t, err, query) {
+			assert.Equal(t, expected, err.Error(), query)
+		}
+	}
+}
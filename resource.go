@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/marcos-venicius/lfi/route"
+	"github.com/marcos-venicius/quang"
+)
+
+// quang symbols can only have letters and underscores, so the path segments
+// are named segment_one..segment_ten everywhere, instead of segment1..N
+var segmentOrdinals = []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten"}
+
+func parseResource(log *log_t, resource string, routes route.Matcher) {
+	rawPath, rawQuery, _ := strings.Cut(resource, "?")
+
+	if parsed, err := url.Parse(resource); err == nil {
+		rawPath = parsed.Path
+		rawQuery = parsed.RawQuery
+	}
+
+	log.path = rawPath
+	log.query = rawQuery
+	log.ext = strings.TrimPrefix(path.Ext(rawPath), ".")
+	log.segments = make([]string, 0)
+
+	for _, segment := range strings.Split(rawPath, "/") {
+		if len(segment) > 0 {
+			log.segments = append(log.segments, segment)
+		}
+	}
+
+	// ParseQuery keeps every valid pair even if some of them are malformed
+	log.params, _ = url.ParseQuery(rawQuery)
+
+	log.route = routes.Resolve(rawPath)
+}
+
+// quang symbols cannot have digits or symbols, so "page[size]" becomes
+// "param_page_size_" everywhere, in queries, formats and templates
+func paramVariableName(key string) string {
+	bytes := []byte(key)
+
+	for i, c := range bytes {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+			bytes[i] = '_'
+		}
+	}
+
+	return "param_" + string(bytes)
+}
+
+// the param of a variable name, preferring the key spelled exactly like
+// it when several keys have the same name, like "a-b" and "a.b"
+func (l log_t) param(name string) string {
+	key := strings.TrimPrefix(name, "param_")
+
+	if values, ok := l.params[key]; ok && len(values) > 0 {
+		return values[0]
+	}
+
+	for key, values := range l.params {
+		if paramVariableName(key) == name && len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	return ""
+}
+
+// param_<name> and segment_<ordinal> are only known while reading each line
+func (l log_t) dynamicField(name string) (string, bool) {
+	if key, ok := strings.CutPrefix(name, "param_"); ok {
+		if len(key) == 0 || paramVariableName(key) != name {
+			return "", false
+		}
+
+		return l.param(name), true
+	}
+
+	if ordinal, ok := strings.CutPrefix(name, "segment_"); ok {
+		for i, o := range segmentOrdinals {
+			if o == ordinal {
+				return l.segment(i + 1), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// the names of the segments, segment_one..segment_ten
+func segmentLabels() []string {
+	labels := make([]string, 0, len(segmentOrdinals))
+
+	for _, ordinal := range segmentOrdinals {
+		labels = append(labels, "segment_"+ordinal)
+	}
+
+	return labels
+}
+
+func (l log_t) segment(n int) string {
+	if n > len(l.segments) {
+		return ""
+	}
+
+	return l.segments[n-1]
+}
+
+// the query variables that only exist for some lines must always be set,
+// otherwise quang would complain about them, or worse, use the value of the
+// previous line.
+func dynamicQueryVariables(query string) []string {
+	variables := make([]string, 0)
+
+	for _, token := range scanQuery(query) {
+		if token.kind != qt_symbol {
+			continue
+		}
+
+		if strings.HasPrefix(token.value, "param_") || strings.HasPrefix(token.value, "segment_") {
+			variables = append(variables, token.value)
+		}
+	}
+
+	return variables
+}
+
+func (l log_t) addResourceValues(values map[string]any) {
+	values["path"] = l.path
+	values["query"] = l.query
+	values["ext"] = l.ext
+	values["route"] = l.route
+	values["segments"] = quang.IntegerType(len(l.segments))
+
+	for i := range min(len(l.segments), len(segmentOrdinals)) {
+		values["segment_"+segmentOrdinals[i]] = l.segments[i]
+	}
+
+	for key := range l.params {
+		name := paramVariableName(key)
+
+		values[name] = l.param(name)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/marcos-venicius/lfi/route"
+	"github.com/marcos-venicius/quang"
+	"github.com/stretchr/testify/assert"
+)
+
+func parsedResource(resource string) log_t {
+	log := log_t{}
+
+	parseResource(&log, resource, route.CreateMatcher())
+
+	return log
+}
+
+func TestParseResource(t *testing.T) {
+	cases := []struct {
+		resource string
+		path     string
+		query    string
+		ext      string
+		segments []string
+		route    string
+	}{
+		{"/", "/", "", "", []string{}, "/"},
+		{"/api/users/42", "/api/users/42", "", "", []string{"api", "users", "42"}, "/api/users/{id}"},
+		{"/static/app.min.js?v=3", "/static/app.min.js", "v=3", "js", []string{"static", "app.min.js"}, "/static/app.min.js"},
+		{"/search?q=a%20b&page=2", "/search", "q=a%20b&page=2", "", []string{"search"}, "/search"},
+		{"/a%2fb?x=%", "/a/b", "x=%", "", []string{"a", "b"}, "/a/b"},
+	}
+
+	for _, c := range cases {
+		log := parsedResource(c.resource)
+
+		assert.Equal(t, c.path, log.path, c.resource)
+		assert.Equal(t, c.query, log.query, c.resource)
+		assert.Equal(t, c.ext, log.ext, c.resource)
+		assert.Equal(t, c.segments, log.segments, c.resource)
+		assert.Equal(t, c.route, log.route, c.resource)
+	}
+}
+
+func TestParamVariableName(t *testing.T) {
+	cases := map[string]string{
+		"user_id":    "param_user_id",
+		"page[size]": "param_page_size_",
+		"v2":         "param_v_",
+		"utm-source": "param_utm_source",
+	}
+
+	for key, expected := range cases {
+		assert.Equal(t, expected, paramVariableName(key), key)
+	}
+}
+
+func TestDynamicField(t *testing.T) {
+	log := parsedResource("/api/users/42?user_id=7&page[size]=10&page_size_=20&v2=x")
+
+	cases := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"param_user_id", "7", true},
+		{"param_missing", "", true},
+		// the key spelled exactly like the name wins
+		{"param_page_size_", "20", true},
+		{"param_v_", "x", true},
+		{"param_v2", "", false},
+		{"param_", "", false},
+		{"segment_one", "api", true},
+		{"segment_three", "42", true},
+		{"segment_ten", "", true},
+		{"segment_eleven", "", false},
+		{"segment1", "", false},
+		{"segmentation", "", false},
+		{"status", "", false},
+	}
+
+	for _, c := range cases {
+		value, ok := log.dynamicField(c.name)
+
+		assert.Equal(t, c.ok, ok, c.name)
+		assert.Equal(t, c.value, value, c.name)
+	}
+}
+
+func TestResourceValues(t *testing.T) {
+	log := parsedResource("/api/users?page[size]=10")
+	values := make(map[string]any)
+
+	log.addResourceValues(values)
+
+	assert.Equal(t, map[string]any{
+		"path":             "/api/users",
+		"query":            "page[size]=10",
+		"ext":              "",
+		"route":            "/api/users",
+		"segments":         quang.IntegerType(2),
+		"segment_one":      "api",
+		"segment_two":      "users",
+		"param_page_size_": "10",
+	}, values)
+}
+
+func TestDynamicQueryVariables(t *testing.T) {
+	assert.Equal(t, []string{"param_user_id", "segment_two"}, dynamicQueryVariables("param_user_id eq '7' and segment_two eq 'users' and path eq '/'"))
+	assert.Empty(t, dynamicQueryVariables("status eq 200"))
+}
+
+func TestFormatterLabels(t *testing.T) {
+	logFormatter := createLogFormatter(&Configs{fields: map[string]int{"latency": 10}})
+
+	for _, format := range []string{"%segment_one %param_user_id %latency", "%param_page_size_ %segment_ten"} {
+		_, err := logFormatter.ParseFormatString(format)
+
+		assert.Nil(t, err, format)
+	}
+
+	for _, format := range []string{"%segmentation", "%segment1", "%segment_eleven", "%param_v2", "%params"} {
+		_, err := logFormatter.ParseFormatString(format)
+
+		assert.NotNil(t, err, format)
+	}
+}
@@ -0,0 +1,177 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+var hexRegex = regexp.MustCompile(`^[0-9a-fA-F]{12,}$`)
+var numberRegex = regexp.MustCompile(`^[0-9]+$`)
+var emailRegex = regexp.MustCompile(`^[^@\s/]+@[^@\s/]+\.[a-zA-Z]{2,}$`)
+var digitRegex = regexp.MustCompile(`[0-9]`)
+
+type segment_t struct {
+	text     string
+	variable bool
+}
+
+type route_t struct {
+	pattern  string
+	segments []segment_t
+	// a trailing "*" matches any number of remaining segments
+	wildcard bool
+}
+
+type Matcher struct {
+	routes []route_t
+}
+
+func splitPath(path string) []string {
+	segments := make([]string, 0)
+
+	for _, segment := range strings.Split(path, "/") {
+		if len(segment) > 0 {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments
+}
+
+// classifies a single path segment, returning the placeholder that should
+// replace it or an empty string when the segment is not an identifier
+func placeholder(segment string) string {
+	switch {
+	case numberRegex.MatchString(segment):
+		return "{id}"
+	case uuidRegex.MatchString(segment):
+		return "{uuid}"
+	case hexRegex.MatchString(segment) && digitRegex.MatchString(segment):
+		return "{hex}"
+	case emailRegex.MatchString(segment):
+		return "{email}"
+	}
+
+	return ""
+}
+
+// Template collapses the identifiers of a path into placeholders, so
+// "/dashboard/user/4f1c.../info" becomes "/dashboard/user/{hex}/info".
+// numbers become {id}, and uuids, hex ids and emails become {uuid}, {hex} and {email}.
+func Template(path string) string {
+	segments := splitPath(path)
+
+	if len(segments) == 0 {
+		return "/"
+	}
+
+	for i, segment := range segments {
+		if p := placeholder(segment); len(p) > 0 {
+			segments[i] = p
+		}
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+func parsePattern(pattern string) (route_t, error) {
+	route := route_t{pattern: pattern}
+
+	if !strings.HasPrefix(pattern, "/") {
+		return route, fmt.Errorf("route \"%s\" should start with \"/\"", pattern)
+	}
+
+	segments := splitPath(pattern)
+
+	for i, segment := range segments {
+		if segment == "*" {
+			if i != len(segments)-1 {
+				return route, fmt.Errorf("route \"%s\" can only have \"*\" at the end", pattern)
+			}
+
+			route.wildcard = true
+			break
+		}
+
+		if strings.HasPrefix(segment, "{") || strings.HasSuffix(segment, "}") {
+			if len(segment) < 3 || !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+				return route, fmt.Errorf("route \"%s\" has an invalid variable \"%s\"", pattern, segment)
+			}
+
+			route.segments = append(route.segments, segment_t{text: segment, variable: true})
+		} else {
+			route.segments = append(route.segments, segment_t{text: segment})
+		}
+	}
+
+	return route, nil
+}
+
+func (r route_t) match(segments []string) bool {
+	if len(segments) < len(r.segments) {
+		return false
+	}
+
+	if !r.wildcard && len(segments) != len(r.segments) {
+		return false
+	}
+
+	for i, segment := range r.segments {
+		if !segment.variable && segment.text != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add a user defined route like "/users/{id}/orders/*".
+// variables match exactly one segment, and "*" matches everything after it.
+// routes are matched in the same order they were added.
+func (m *Matcher) Add(pattern string) error {
+	if len(strings.TrimSpace(pattern)) == 0 {
+		return errors.New("route cannot be empty")
+	}
+
+	route, err := parsePattern(pattern)
+
+	if err != nil {
+		return err
+	}
+
+	m.routes = append(m.routes, route)
+
+	return nil
+}
+
+// Match returns the first user defined route matching the path
+func (m Matcher) Match(path string) (string, bool) {
+	segments := splitPath(path)
+
+	for _, route := range m.routes {
+		if route.match(segments) {
+			return route.pattern, true
+		}
+	}
+
+	return "", false
+}
+
+// Resolve returns the user defined route of the path or its template
+// when none of them match
+func (m Matcher) Resolve(path string) string {
+	if route, ok := m.Match(path); ok {
+		return route
+	}
+
+	return Template(path)
+}
+
+func CreateMatcher() Matcher {
+	return Matcher{
+		routes: make([]route_t, 0),
+	}
+}
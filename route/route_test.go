@@ -0,0 +1,64 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	cases := map[string]string{
+		"":           "/",
+		"/":          "/",
+		"/users/42":  "/users/{id}",
+		"/users/42/": "/users/{id}",
+		"/dashboard/user/0cc175b9c0f1b6a831c399e269772661/info": "/dashboard/user/{hex}/info",
+		"/orders/123e4567-e89b-12d3-a456-426614174000/items/7":  "/orders/{uuid}/items/{id}",
+		"/invite/john.doe@example.com":                          "/invite/{email}",
+		"/static/deadbeef1234cafe":                              "/static/{hex}",
+		"/static/facadefacade":                                  "/static/facadefacade",
+		"/api/v2/products":                                      "/api/v2/products",
+	}
+
+	for path, expected := range cases {
+		assert.Equal(t, expected, Template(path), path)
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m := CreateMatcher()
+
+	assert.Nil(t, m.Add("/users/{user}/info"))
+	assert.Nil(t, m.Add("/users/{user}"))
+	assert.Nil(t, m.Add("/assets/*"))
+
+	route, ok := m.Match("/users/john/info")
+
+	assert.True(t, ok)
+	assert.Equal(t, "/users/{user}/info", route)
+
+	route, ok = m.Match("/users/john")
+
+	assert.True(t, ok)
+	assert.Equal(t, "/users/{user}", route)
+
+	route, ok = m.Match("/assets/css/main.css")
+
+	assert.True(t, ok)
+	assert.Equal(t, "/assets/*", route)
+
+	_, ok = m.Match("/users/john/orders")
+
+	assert.False(t, ok)
+
+	assert.Equal(t, "/products/{id}", m.Resolve("/products/10"))
+}
+
+func TestMatcherInvalidRoutes(t *testing.T) {
+	m := CreateMatcher()
+
+	assert.Equal(t, "route cannot be empty", m.Add(" ").Error())
+	assert.Equal(t, "route \"users\" should start with \"/\"", m.Add("users").Error())
+	assert.Equal(t, "route \"/a/*/b\" can only have \"*\" at the end", m.Add("/a/*/b").Error())
+	assert.Equal(t, "route \"/a/{id\" has an invalid variable \"{id\"", m.Add("/a/{id").Error())
+}
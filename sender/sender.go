@@ -0,0 +1,254 @@
+package sender
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_BATCH_SIZE     = 100
+	DEFAULT_FLUSH_INTERVAL = 5 * time.Second
+	DEFAULT_RETRIES        = 3
+	DEFAULT_BACKOFF        = time.Second
+	DEFAULT_TIMEOUT        = 10 * time.Second
+	// items waiting to be sent, after it new items are dropped
+	QUEUE_SIZE = 10000
+)
+
+// Endpoint posts the bodies to an url
+type Endpoint struct {
+	url string
+	// the endpoint in the errors, like "webhook" in "webhook answered 503 Service Unavailable"
+	name string
+	// extra headers of the requests, like an authorization
+	headers map[string]string
+	client  *http.Client
+	// tells if an answer is worth sending the body again. the network
+	// errors always are
+	retryable func(status int) bool
+}
+
+// the network errors, the rate limits and the server errors are worth
+// sending the batch again. the other answers would reject it again
+func RetryServerErrors(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func CreateEndpoint(url string, name string, headers map[string]string, timeout time.Duration, retryable func(status int) bool) *Endpoint {
+	if timeout <= 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+
+	if retryable == nil {
+		retryable = RetryServerErrors
+	}
+
+	return &Endpoint{
+		url:       url,
+		name:      name,
+		headers:   headers,
+		client:    &http.Client{Timeout: timeout},
+		retryable: retryable,
+	}
+}
+
+// Post sends the body once, and tells if it failed in a way that is worth
+// retrying
+func (e *Endpoint) Post(body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+
+	if err != nil {
+		return false, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	for key, value := range e.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := e.client.Do(request)
+
+	if err != nil {
+		return true, err
+	}
+
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return e.retryable(response.StatusCode), fmt.Errorf("%s answered %s", e.name, response.Status)
+	}
+
+	return false, nil
+}
+
+type Options[T any] struct {
+	BatchSize     int
+	FlushInterval time.Duration
+	// how many times a batch is sent again, waiting Backoff, 2 * Backoff, 4 * Backoff...
+	Retries int
+	Backoff time.Duration
+	// the json body of a batch
+	Encode func(batch []T) ([]byte, error)
+	// called before each batch is sent, and on the flushes without a batch.
+	// while it returns an error the batches are not sent, they fail with it
+	Ready func() error
+	// called with the batches that could not be sent, how many items they
+	// have and if they are worth sending again
+	Failed func(body []byte, items int, retry bool, err error)
+	// called with the errors of the deliveries, which happen in background
+	OnError func(error)
+}
+
+// Sender posts items to an endpoint, in batches, from background
+type Sender[T any] struct {
+	endpoint *Endpoint
+	options  Options[T]
+
+	queue   chan T
+	done    chan struct{}
+	dropped int
+	mutex   sync.Mutex
+}
+
+func Create[T any](endpoint *Endpoint, options Options[T]) *Sender[T] {
+	if options.BatchSize < 1 {
+		options.BatchSize = DEFAULT_BATCH_SIZE
+	}
+
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DEFAULT_FLUSH_INTERVAL
+	}
+
+	if options.Retries < 0 {
+		options.Retries = 0
+	}
+
+	if options.Backoff <= 0 {
+		options.Backoff = DEFAULT_BACKOFF
+	}
+
+	if options.Ready == nil {
+		options.Ready = func() error { return nil }
+	}
+
+	if options.OnError == nil {
+		options.OnError = func(error) {}
+	}
+
+	if options.Failed == nil {
+		options.Failed = func(_ []byte, items int, _ bool, err error) {
+			options.OnError(fmt.Errorf("%s, %d items were not sent", err.Error(), items))
+		}
+	}
+
+	s := &Sender[T]{
+		endpoint: endpoint,
+		options:  options,
+		queue:    make(chan T, QUEUE_SIZE),
+		done:     make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
+
+// Send queues the item without blocking. when the queue is full the item
+// is dropped
+func (s *Sender[T]) Send(item T) {
+	select {
+	case s.queue <- item:
+	default:
+		s.mutex.Lock()
+		s.dropped++
+		s.mutex.Unlock()
+	}
+}
+
+// how many items were dropped because the queue was full
+func (s *Sender[T]) Dropped() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.dropped
+}
+
+// Close sends the queued items and waits for them
+func (s *Sender[T]) Close() {
+	close(s.queue)
+
+	<-s.done
+}
+
+func (s *Sender[T]) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.options.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]T, 0, s.options.BatchSize)
+
+	for {
+		select {
+		case item, ok := <-s.queue:
+			if !ok {
+				if len(batch) > 0 {
+					s.deliver(batch)
+				}
+
+				return
+			}
+
+			batch = append(batch, item)
+
+			if len(batch) >= s.options.BatchSize {
+				s.deliver(batch)
+				batch = make([]T, 0, s.options.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.deliver(batch)
+				batch = make([]T, 0, s.options.BatchSize)
+			} else {
+				s.options.Ready()
+			}
+		}
+	}
+}
+
+func (s *Sender[T]) deliver(batch []T) {
+	body, err := s.options.Encode(batch)
+
+	if err != nil {
+		s.options.OnError(err)
+		return
+	}
+
+	if err := s.options.Ready(); err != nil {
+		s.options.Failed(body, len(batch), true, err)
+		return
+	}
+
+	backoff := s.options.Backoff
+
+	for attempt := 0; ; attempt++ {
+		retry, err := s.endpoint.Post(body)
+
+		if err == nil {
+			return
+		}
+
+		if !retry || attempt >= s.options.Retries {
+			s.options.Failed(body, len(batch), retry, err)
+			return
+		}
+
+		time.Sleep(backoff)
+
+		backoff *= 2
+	}
+}
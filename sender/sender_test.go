@@ -0,0 +1,213 @@
+package sender
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marcos-venicius/lfi/sender/sendertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// a failed batch, as Failed got it
+type failure_t struct {
+	items int
+	retry bool
+	err   string
+}
+
+type failures_t struct {
+	list  []failure_t
+	mutex sync.Mutex
+}
+
+func (f *failures_t) add(_ []byte, items int, retry bool, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.list = append(f.list, failure_t{items: items, retry: retry, err: err.Error()})
+}
+
+func (f *failures_t) get() []failure_t {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]failure_t{}, f.list...)
+}
+
+func createSender(r *sendertest.Receiver, options Options[string]) *Sender[string] {
+	options.Encode = func(batch []string) ([]byte, error) { return json.Marshal(batch) }
+
+	return Create(CreateEndpoint(r.URL(), "receiver", map[string]string{"Authorization": "Bearer token"}, time.Second, nil), options)
+}
+
+// the items received, in order
+func received(t *testing.T, r *sendertest.Receiver) []string {
+	items := make([]string, 0)
+
+	for _, body := range r.Bodies() {
+		batch := make([]string, 0)
+
+		assert.Nil(t, json.Unmarshal(body, &batch))
+
+		items = append(items, batch...)
+	}
+
+	return items
+}
+
+func TestBatches(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	s := createSender(r, Options[string]{BatchSize: 3, FlushInterval: time.Hour})
+
+	for _, item := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		s.Send(item)
+	}
+
+	s.Close()
+
+	assert.Equal(t, 3, len(r.Bodies()))
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f", "g"}, received(t, r))
+	assert.Equal(t, "application/json", r.Headers()[0].Get("Content-Type"))
+	assert.Equal(t, "Bearer token", r.Headers()[0].Get("Authorization"))
+	assert.Equal(t, 0, s.Dropped())
+}
+
+func TestFlushInterval(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	s := createSender(r, Options[string]{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	defer s.Close()
+
+	s.Send("a")
+
+	assert.Eventually(t, func() bool { return len(received(t, r)) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestRetries(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	r.Fail(http.StatusInternalServerError, 2)
+
+	failures := &failures_t{}
+	s := createSender(r, Options[string]{BatchSize: 1, Retries: 3, Backoff: time.Millisecond, Failed: failures.add})
+
+	s.Send("a")
+	s.Close()
+
+	assert.Equal(t, 3, r.Requests())
+	assert.Equal(t, []string{"a"}, received(t, r))
+	assert.Empty(t, failures.get())
+}
+
+func TestRetriesRunOut(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	r.Fail(http.StatusServiceUnavailable, -1)
+
+	failures := &failures_t{}
+	s := createSender(r, Options[string]{BatchSize: 2, Retries: 2, Backoff: time.Millisecond, Failed: failures.add})
+
+	s.Send("a")
+	s.Send("b")
+	s.Close()
+
+	assert.Equal(t, 3, r.Requests())
+	assert.Equal(t, []failure_t{{items: 2, retry: true, err: "receiver answered 503 Service Unavailable"}}, failures.get())
+}
+
+func TestNotRetryable(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	r.Fail(http.StatusUnprocessableEntity, -1)
+
+	failures := &failures_t{}
+	s := createSender(r, Options[string]{BatchSize: 1, Retries: 3, Backoff: time.Millisecond, Failed: failures.add})
+
+	s.Send("a")
+	s.Close()
+
+	assert.Equal(t, 1, r.Requests())
+	assert.Equal(t, []failure_t{{items: 1, retry: false, err: "receiver answered 422 Unprocessable Entity"}}, failures.get())
+}
+
+func TestRetryPolicy(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	r.Fail(http.StatusInternalServerError, -1)
+
+	failures := &failures_t{}
+	endpoint := CreateEndpoint(r.URL(), "receiver", nil, time.Second, func(status int) bool { return status == http.StatusTooManyRequests })
+	s := Create(endpoint, Options[string]{BatchSize: 1, Retries: 3, Backoff: time.Millisecond, Encode: func(batch []string) ([]byte, error) { return json.Marshal(batch) }, Failed: failures.add})
+
+	s.Send("a")
+	s.Close()
+
+	// the policy doesn't retry the server errors
+	assert.Equal(t, 1, r.Requests())
+	assert.False(t, failures.get()[0].retry)
+}
+
+func TestDefaultFailed(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	r.Fail(http.StatusBadRequest, -1)
+
+	errors := make(chan error, 1)
+	s := createSender(r, Options[string]{BatchSize: 1, OnError: func(err error) { errors <- err }})
+
+	s.Send("a")
+	s.Close()
+
+	assert.Equal(t, "receiver answered 400 Bad Request, 1 items were not sent", (<-errors).Error())
+}
+
+func TestReady(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	var mutex sync.Mutex
+
+	calls := 0
+	failures := &failures_t{}
+	s := createSender(r, Options[string]{BatchSize: 1, FlushInterval: 10 * time.Millisecond, Failed: failures.add, Ready: func() error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		calls++
+
+		return fmt.Errorf("not ready")
+	}})
+
+	s.Send("a")
+
+	// it's also called on the flushes without a batch
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return calls > 2
+	}, time.Second, 5*time.Millisecond)
+
+	s.Close()
+
+	assert.Equal(t, 0, r.Requests())
+	assert.Equal(t, []failure_t{{items: 1, retry: true, err: "not ready"}}, failures.get())
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	for status, expected := range map[int]bool{429: true, 500: true, 503: true, 400: false, 404: false, 422: false} {
+		assert.Equal(t, expected, RetryServerErrors(status), status)
+	}
+}
@@ -0,0 +1,116 @@
+package sendertest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Receiver is a stub endpoint, keeping the json bodies it gets
+type Receiver struct {
+	Server *httptest.Server
+
+	bodies   [][]byte
+	paths    []string
+	headers  []http.Header
+	requests int
+	// the bodies that are not json, answered with 400
+	rejected int
+	// answered to the next failures requests, or to every one while negative
+	status   int
+	failures int
+	mutex    sync.Mutex
+}
+
+func Create() *Receiver {
+	r := &Receiver{}
+
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		r.requests++
+		r.paths = append(r.paths, request.URL.Path)
+		r.headers = append(r.headers, request.Header)
+
+		if r.failures != 0 {
+			r.failures--
+			w.WriteHeader(r.status)
+			return
+		}
+
+		body, _ := io.ReadAll(request.Body)
+
+		if !json.Valid(body) {
+			r.rejected++
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		r.bodies = append(r.bodies, body)
+	}))
+
+	return r
+}
+
+func (r *Receiver) URL() string {
+	return r.Server.URL
+}
+
+func (r *Receiver) Close() {
+	r.Server.Close()
+}
+
+// Fail answers status to the next n requests, or to every one when n is
+// negative, until Recover
+func (r *Receiver) Fail(status int, n int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.status = status
+	r.failures = n
+}
+
+func (r *Receiver) Recover() {
+	r.Fail(0, 0)
+}
+
+// the json bodies received, in order
+func (r *Receiver) Bodies() [][]byte {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([][]byte{}, r.bodies...)
+}
+
+// how many requests were received, answered or not
+func (r *Receiver) Requests() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.requests
+}
+
+func (r *Receiver) Paths() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]string{}, r.paths...)
+}
+
+func (r *Receiver) Headers() []http.Header {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]http.Header{}, r.headers...)
+}
+
+// how many bodies were not json
+func (r *Receiver) Rejected() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.rejected
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/marcos-venicius/lfi/metrics"
+	"github.com/marcos-venicius/quang"
+)
+
+// the metrics when the config declares none
+var defaultMetrics = []string{
+	"counter lfi_requests_total labels method,status,route",
+	"histogram lfi_response_size_bytes labels route field size",
+}
+
+// a metric of the config, with its query
+type served_metric_t struct {
+	*metrics.Metric
+	when      *quang.Quang
+	variables []string
+}
+
+func (m *served_metric_t) add(log log_t, values map[string]any) {
+	if m.when != nil {
+		log.bindQueryVariables(m.when, m.variables)
+
+		if matches, err := m.when.Eval(); err != nil || !matches {
+			return
+		}
+	}
+
+	value := 1.0
+
+	if len(m.Definition.Field) > 0 {
+		number, ok := numberOf(log.field(m.Definition.Field, values))
+
+		if !ok {
+			return
+		}
+
+		value = number
+	}
+
+	labels := make([]string, len(m.Definition.Labels))
+
+	for i, label := range m.Definition.Labels {
+		labels[i] = fmt.Sprint(log.field(label, values))
+	}
+
+	m.Add(labels, value)
+}
+
+func createServedMetric(registry *metrics.Registry, definition metrics.Definition, configs *Configs) (*served_metric_t, error) {
+	for _, label := range definition.Labels {
+		if !isKnownField(label, configs) {
+			return nil, fmt.Errorf("unknown field \"%s\" in the labels of the metric %s", label, definition.Name)
+		}
+	}
+
+	if len(definition.Field) > 0 && !isKnownField(definition.Field, configs) {
+		return nil, fmt.Errorf("unknown field \"%s\" in the metric %s", definition.Field, definition.Name)
+	}
+
+	served := &served_metric_t{}
+
+	if len(definition.When) > 0 {
+		q, err := compileQuery(definition.When)
+
+		if err != nil {
+			return nil, fmt.Errorf("the metric %s: %s", definition.Name, err.Error())
+		}
+
+		served.when = q
+		served.variables = dynamicQueryVariables(definition.When)
+	}
+
+	metric, err := registry.Register(definition)
+
+	if err != nil {
+		return nil, err
+	}
+
+	served.Metric = metric
+
+	return served, nil
+}
+
+func runServeMetrics(args []string) int {
+	flags := flag.NewFlagSet("serve-metrics", flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of lfi serve-metrics: lfi serve-metrics [flags] address [file]\n\nserves prometheus metrics of the logs of stdin, or of the lines appended to file, at http://address/metrics, like \"lfi serve-metrics :9100 access.log\"\n\n")
+		flags.PrintDefaults()
+	}
+
+	common := addLogFlags(flags)
+	path := flags.String("path", "/metrics", "the http path of the metrics")
+
+	arguments := parseInterspersed(flags, args)
+
+	if len(arguments) < 1 || len(arguments) > 2 {
+		flags.Usage()
+		return 1
+	}
+
+	configs, q, dynamicVariables, err := common.load()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	registry := metrics.CreateRegistry()
+
+	lines, _ := registry.Register(metrics.Definition{Type: metrics.COUNTER, Name: "lfi_lines_total", Help: "the lines read"})
+	failures, _ := registry.Register(metrics.Definition{Type: metrics.COUNTER, Name: "lfi_parse_failures_total", Help: "the lines that don't match the log regex"})
+
+	definitions := configs.metrics
+
+	if len(definitions) == 0 {
+		for _, text := range defaultMetrics {
+			definition, _ := metrics.ParseDefinition(text)
+			definitions = append(definitions, definition)
+		}
+	}
+
+	served := make([]*served_metric_t, 0, len(definitions))
+
+	for _, definition := range definitions {
+		metric, err := createServedMetric(registry, definition, configs)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			return 1
+		}
+
+		served = append(served, metric)
+	}
+
+	var input io.Reader = os.Stdin
+
+	if len(arguments) == 2 {
+		follower, err := followFile(arguments[1])
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			return 1
+		}
+
+		defer follower.Close()
+
+		input = follower
+	}
+
+	// listens before reading, so a busy address fails right away
+	listener, err := net.Listen("tcp", arguments[0])
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	mux := http.NewServeMux()
+
+	mux.Handle(*path, registry.Handler())
+
+	errors := make(chan error, 1)
+
+	go func() {
+		errors <- http.Serve(listener, mux)
+	}()
+
+	fmt.Fprintf(os.Stderr, "serving the metrics at http://%s%s\n", listener.Addr(), *path)
+
+	logs := make(chan []byte, 1024)
+
+	go func() {
+		readLines(input, logs)
+		close(logs)
+	}()
+
+	for line := range logs {
+		lines.Add(nil, 1)
+
+		log, err := parseKongLogLine(string(line), *common.breakParamsOut, configs)
+
+		if err != nil {
+			failures.Add(nil, 1)
+			continue
+		}
+
+		log.bindQueryVariables(q, dynamicVariables)
+
+		if matches, err := q.Eval(); err != nil || !matches {
+			continue
+		}
+
+		values := log.values()
+
+		for _, metric := range served {
+			metric.add(log, values)
+		}
+	}
+
+	// the metrics are still served after the end of stdin, until lfi is stopped
+	if err := <-errors; err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+	}
+
+	return 1
+}
@@ -0,0 +1,180 @@
+package session
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// the inactivity after which the next request of a client starts a new session
+	DEFAULT_TIMEOUT = 30 * time.Minute
+	// only the first paths of a session are kept
+	MAX_PATHS = 50
+)
+
+// Session is the requests of a client without long pauses between them
+type Session struct {
+	// the values of the key fields, like the ip and the user agent
+	Values   []any
+	Start    time.Time
+	End      time.Time
+	Requests int
+	Errors   int
+	// the paths in the order they were requested, at most MAX_PATHS
+	Paths []string
+}
+
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// requests per minute, or the requests when the session is shorter than a minute
+func (s Session) Rate() float64 {
+	minutes := s.Duration().Minutes()
+
+	if minutes < 1 {
+		return float64(s.Requests)
+	}
+
+	return float64(s.Requests) / minutes
+}
+
+// Tracker splits the requests of each client into sessions
+type Tracker struct {
+	timeout time.Duration
+	// how many sessions are shown, so only the closed sessions with the
+	// most requests are kept. 0 keeps every session
+	limit  int
+	open   map[string]*Session
+	closed []*Session
+	// the time of the newest request and of the last time the idle sessions
+	// were closed
+	newest time.Time
+	swept  time.Time
+	mutex  sync.Mutex
+}
+
+// limit is how many sessions are shown, 0 for every one
+func Create(timeout time.Duration, limit int) *Tracker {
+	if timeout <= 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+
+	return &Tracker{
+		timeout: timeout,
+		limit:   max(limit, 0),
+		open:    make(map[string]*Session),
+		closed:  make([]*Session, 0),
+	}
+}
+
+// sessions with more requests come first, then the older ones
+func before(a *Session, b *Session) bool {
+	if a.Requests != b.Requests {
+		return a.Requests > b.Requests
+	}
+
+	return a.Start.Before(b.Start)
+}
+
+func (t *Tracker) close(s *Session) {
+	t.closed = append(t.closed, s)
+
+	// the closed sessions are trimmed once they're twice the limit, so it
+	// doesn't sort on every close
+	if t.limit > 0 && len(t.closed) >= 2*t.limit {
+		sort.Slice(t.closed, func(i, j int) bool { return before(t.closed[i], t.closed[j]) })
+		clear(t.closed[t.limit:])
+
+		t.closed = t.closed[:t.limit]
+	}
+}
+
+// closes the sessions idle for longer than the timeout, by the time of the
+// newest request
+func (t *Tracker) sweep() {
+	for key, s := range t.open {
+		if t.newest.Sub(s.End) > t.timeout {
+			t.close(s)
+			delete(t.open, key)
+		}
+	}
+
+	t.swept = t.newest
+}
+
+// Add counts a request of the client identified by key at t, which is
+// usually the time of the log
+func (t *Tracker) Add(key string, values []any, at time.Time, path string, failed bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if at.After(t.newest) {
+		t.newest = at
+	}
+
+	// the idle sessions are closed every tenth of the timeout, so Add stays
+	// cheap with many clients
+	if t.newest.Sub(t.swept) > t.timeout/10 {
+		t.sweep()
+	}
+
+	s, ok := t.open[key]
+
+	if ok && at.Sub(s.End) > t.timeout {
+		t.close(s)
+		ok = false
+	}
+
+	if !ok {
+		s = &Session{Values: values, Start: at, End: at, Paths: make([]string, 0, 8)}
+		t.open[key] = s
+	}
+
+	// logs a little out of order still belong to the session
+	if at.Before(s.Start) {
+		s.Start = at
+	}
+
+	if at.After(s.End) {
+		s.End = at
+	}
+
+	s.Requests++
+
+	if failed {
+		s.Errors++
+	}
+
+	if len(s.Paths) < MAX_PATHS {
+		s.Paths = append(s.Paths, path)
+	}
+}
+
+// Sessions returns the sessions, the finished and the open ones, with the
+// most requests first, at most the limit
+func (t *Tracker) Sessions() []Session {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.sweep()
+
+	sessions := make([]Session, 0, len(t.closed)+len(t.open))
+
+	for _, s := range t.closed {
+		sessions = append(sessions, *s)
+	}
+
+	for _, s := range t.open {
+		sessions = append(sessions, *s)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return before(&sessions[i], &sessions[j]) })
+
+	if t.limit > 0 && len(sessions) > t.limit {
+		sessions = sessions[:t.limit]
+	}
+
+	return sessions
+}
@@ -0,0 +1,120 @@
+package session
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2024, 10, 10, 13, 0, 0, 0, time.UTC)
+
+func TestSplitsOnInactivity(t *testing.T) {
+	tracker := Create(10*time.Minute, 0)
+	values := []any{"10.0.0.1", "curl/8"}
+
+	tracker.Add("a", values, start, "/", false)
+	tracker.Add("a", values, start.Add(5*time.Minute), "/login", true)
+	tracker.Add("a", values, start.Add(14*time.Minute), "/account", false)
+	tracker.Add("a", values, start.Add(30*time.Minute), "/", false)
+
+	sessions := tracker.Sessions()
+
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, Session{
+		Values:   values,
+		Start:    start,
+		End:      start.Add(14 * time.Minute),
+		Requests: 3,
+		Errors:   1,
+		Paths:    []string{"/", "/login", "/account"},
+	}, sessions[0])
+	assert.Equal(t, 14*time.Minute, sessions[0].Duration())
+	assert.Equal(t, 1, sessions[1].Requests)
+	assert.Equal(t, time.Duration(0), sessions[1].Duration())
+}
+
+func TestClientsAreApart(t *testing.T) {
+	tracker := Create(time.Minute, 0)
+
+	tracker.Add("a", nil, start, "/", false)
+	tracker.Add("b", nil, start, "/", false)
+	tracker.Add("b", nil, start.Add(time.Second), "/", false)
+
+	sessions := tracker.Sessions()
+
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, 2, sessions[0].Requests)
+}
+
+func TestOutOfOrder(t *testing.T) {
+	tracker := Create(time.Minute, 0)
+
+	tracker.Add("a", nil, start.Add(10*time.Second), "/", false)
+	tracker.Add("a", nil, start, "/", false)
+
+	sessions := tracker.Sessions()
+
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, start, sessions[0].Start)
+	assert.Equal(t, 10*time.Second, sessions[0].Duration())
+}
+
+func TestPathsAreLimited(t *testing.T) {
+	tracker := Create(time.Minute, 0)
+
+	for i := range MAX_PATHS + 10 {
+		tracker.Add("a", nil, start.Add(time.Duration(i)*time.Second), "/", false)
+	}
+
+	sessions := tracker.Sessions()
+
+	assert.Equal(t, MAX_PATHS+10, sessions[0].Requests)
+	assert.Len(t, sessions[0].Paths, MAX_PATHS)
+}
+
+func TestIdleSessionsAreClosed(t *testing.T) {
+	tracker := Create(time.Minute, 0)
+
+	tracker.Add("a", nil, start, "/", false)
+	tracker.Add("b", nil, start, "/", false)
+	tracker.Add("c", nil, start.Add(2*time.Minute), "/", false)
+
+	// a and b never came back, but their sessions are closed anyway
+	assert.Len(t, tracker.open, 1)
+	assert.Len(t, tracker.closed, 2)
+	assert.Len(t, tracker.Sessions(), 3)
+}
+
+func TestClosedSessionsAreLimited(t *testing.T) {
+	tracker := Create(time.Minute, 2)
+
+	for i := range 10 {
+		at := start.Add(time.Duration(i) * time.Hour)
+		requests := 1
+
+		// the sixth client has the most requests
+		if i == 5 {
+			requests = 4
+		}
+
+		for j := range requests {
+			tracker.Add(fmt.Sprint(i), nil, at.Add(time.Duration(j)*time.Second), "/", false)
+		}
+	}
+
+	assert.LessOrEqual(t, len(tracker.closed), 4)
+
+	sessions := tracker.Sessions()
+
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, 4, sessions[0].Requests)
+	assert.Equal(t, start.Add(5*time.Hour), sessions[0].Start)
+	assert.Equal(t, start, sessions[1].Start)
+}
+
+func TestRate(t *testing.T) {
+	assert.Equal(t, 5.0, Session{Start: start, End: start.Add(30 * time.Second), Requests: 5}.Rate())
+	assert.Equal(t, 30.0, Session{Start: start, End: start.Add(2 * time.Minute), Requests: 60}.Rate())
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marcos-venicius/lfi/session"
+)
+
+// how many paths of each session the table shows
+const sessionTablePaths = 5
+
+// session_report_t groups the logs into sessions, for -sessions
+type session_report_t struct {
+	fields  []string
+	tracker *session.Tracker
+	output  string
+}
+
+func createSessionReport(fields []string, timeout time.Duration, top int, output string) *session_report_t {
+	return &session_report_t{
+		fields:  fields,
+		tracker: session.Create(timeout, top),
+		output:  output,
+	}
+}
+
+func (r *session_report_t) add(log log_t) {
+	values := log.values()
+	keyValues := make([]any, len(r.fields))
+	keys := make([]string, len(r.fields))
+
+	for i, field := range r.fields {
+		keyValues[i] = log.field(field, values)
+		keys[i] = fmt.Sprint(keyValues[i])
+	}
+
+	r.tracker.Add(strings.Join(keys, "\x00"), keyValues, log.judgedTime(), log.path, log.statusCode >= 400)
+}
+
+// "/ > /login > /account (+12)"
+func displayPaths(s session.Session, limit int) string {
+	paths := s.Paths[:min(len(s.Paths), limit)]
+	text := strings.Join(paths, " > ")
+
+	if s.Requests > len(paths) {
+		text += fmt.Sprintf(" (+%d)", s.Requests-len(paths))
+	}
+
+	return text
+}
+
+func (r *session_report_t) write(w io.Writer) error {
+	sessions := r.tracker.Sessions()
+
+	columns := []string{"start", "duration", "requests", "errors", "per_minute", "paths"}
+
+	switch r.output {
+	case "json":
+		rows := make([]map[string]any, 0, len(sessions))
+
+		for _, s := range sessions {
+			row := map[string]any{
+				"start":            s.Start.Format(logTimeLayout),
+				"end":              s.End.Format(logTimeLayout),
+				"duration_seconds": s.Duration().Seconds(),
+				"requests":         s.Requests,
+				"errors":           s.Errors,
+				"per_minute":       roundResult(s.Rate()),
+				"paths":            s.Paths,
+			}
+
+			for i, field := range r.fields {
+				row[field] = s.Values[i]
+			}
+
+			rows = append(rows, row)
+		}
+
+		return json.NewEncoder(w).Encode(rows)
+	case "csv":
+		writer := csv.NewWriter(w)
+
+		writer.Write(append(append([]string{}, r.fields...), columns...))
+
+		for _, s := range sessions {
+			row := make([]string, 0, len(r.fields)+len(columns))
+
+			for _, value := range s.Values {
+				row = append(row, displayResult(value))
+			}
+
+			row = append(row, s.Start.Format(logTimeLayout), displayDuration(s.Duration()), fmt.Sprint(s.Requests), fmt.Sprint(s.Errors), displayResult(roundResult(s.Rate())), displayPaths(s, session.MAX_PATHS))
+
+			writer.Write(row)
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, field := range r.fields {
+		fmt.Fprintf(writer, "%s\t", strings.ToUpper(field))
+	}
+
+	fmt.Fprintf(writer, "%s\n", strings.ToUpper(strings.Join(columns, "\t")))
+
+	for _, s := range sessions {
+		for _, value := range s.Values {
+			fmt.Fprintf(writer, "%s\t", displayResult(value))
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%s\t%s\n", s.Start.Format(logTimeLayout), displayDuration(s.Duration()), s.Requests, s.Errors, displayResult(roundResult(s.Rate())), displayPaths(s, sessionTablePaths))
+	}
+
+	return writer.Flush()
+}
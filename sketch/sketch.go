@@ -0,0 +1,103 @@
+package sketch
+
+import (
+	"math"
+	"sort"
+)
+
+// the relative error of the quantiles
+const DEFAULT_ACCURACY = 0.01
+
+// how many buckets a sketch keeps before merging the smallest ones
+const MAX_BUCKETS = 2048
+
+// Sketch is a DDSketch (https://arxiv.org/abs/1908.10693): a streaming
+// quantile estimator with relative error guarantees and bounded memory.
+// only non negative values are supported, negative values are counted as zero.
+type Sketch struct {
+	gamma      float64
+	logGamma   float64
+	buckets    map[int]uint64
+	zeros      uint64
+	count      uint64
+	maxBuckets int
+}
+
+func Create(accuracy float64) *Sketch {
+	gamma := (1 + accuracy) / (1 - accuracy)
+
+	return &Sketch{
+		gamma:      gamma,
+		logGamma:   math.Log(gamma),
+		buckets:    make(map[int]uint64),
+		maxBuckets: MAX_BUCKETS,
+	}
+}
+
+func (s *Sketch) Add(value float64) {
+	s.count++
+
+	if value <= 0 || math.IsNaN(value) {
+		s.zeros++
+		return
+	}
+
+	index := int(math.Ceil(math.Log(value) / s.logGamma))
+
+	s.buckets[index]++
+
+	if len(s.buckets) > s.maxBuckets {
+		s.collapse()
+	}
+}
+
+// merges the two smallest buckets, losing accuracy only on the lowest quantiles
+func (s *Sketch) collapse() {
+	keys := s.keys()
+
+	s.buckets[keys[1]] += s.buckets[keys[0]]
+	delete(s.buckets, keys[0])
+}
+
+func (s *Sketch) keys() []int {
+	keys := make([]int, 0, len(s.buckets))
+
+	for key := range s.buckets {
+		keys = append(keys, key)
+	}
+
+	sort.Ints(keys)
+
+	return keys
+}
+
+func (s *Sketch) Count() uint64 {
+	return s.count
+}
+
+// Quantile returns the estimated value at q, between 0 and 1
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+
+	q = math.Max(0, math.Min(1, q))
+
+	rank := uint64(q * float64(s.count-1))
+
+	if rank < s.zeros {
+		return 0
+	}
+
+	seen := s.zeros
+
+	for _, key := range s.keys() {
+		seen += s.buckets[key]
+
+		if seen > rank {
+			return 2 * math.Pow(s.gamma, float64(key)) / (s.gamma + 1)
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,61 @@
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantileAccuracy(t *testing.T) {
+	s := Create(DEFAULT_ACCURACY)
+	values := make([]float64, 0, 10000)
+
+	random := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 10000; i++ {
+		value := random.ExpFloat64() * 1000
+
+		values = append(values, value)
+		s.Add(value)
+	}
+
+	sort.Float64s(values)
+
+	for _, q := range []float64{0.5, 0.9, 0.95, 0.99} {
+		expected := values[int(q*float64(len(values)-1))]
+
+		assert.InEpsilon(t, expected, s.Quantile(q), DEFAULT_ACCURACY, "quantile %f", q)
+	}
+
+	assert.Equal(t, uint64(10000), s.Count())
+}
+
+func TestZerosAndEmpty(t *testing.T) {
+	s := Create(DEFAULT_ACCURACY)
+
+	assert.Equal(t, 0.0, s.Quantile(0.5))
+
+	s.Add(0)
+	s.Add(0)
+	s.Add(-1)
+	s.Add(100)
+
+	assert.Equal(t, 0.0, s.Quantile(0.5))
+	assert.InEpsilon(t, 100, s.Quantile(1), DEFAULT_ACCURACY)
+}
+
+func TestBoundedBuckets(t *testing.T) {
+	s := Create(DEFAULT_ACCURACY)
+
+	s.maxBuckets = 100
+
+	for i := 0; i < 100000; i++ {
+		s.Add(math.Pow(1.05, float64(i%1000)))
+	}
+
+	assert.LessOrEqual(t, len(s.buckets), 100)
+	assert.InEpsilon(t, math.Pow(1.05, 989), s.Quantile(0.99), DEFAULT_ACCURACY)
+}
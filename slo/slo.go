@@ -0,0 +1,235 @@
+package slo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the windows of the burn rates when none is given
+var DefaultWindows = []time.Duration{5 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+// Definition is an slo, like 99.9% of the requests to /checkout should not fail
+type Definition struct {
+	Name string
+	// the fraction of good events, like 0.999
+	Target float64
+	// the quang query of the events of the slo
+	Selector string
+	// the quang query of the good events, among the selected ones
+	Good string
+}
+
+var definitionRegex = regexp.MustCompile(`^([a-zA-Z0-9_-]+)\s+([\d.]+)%\s+when\s+(.+)$`)
+
+// the index of the first word outside of the quotes, or -1
+func findWord(text string, word string) int {
+	quoted := false
+
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && quoted:
+			i++
+		case text[i] == '\'':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(text[i:], word) && (i == 0 || text[i-1] == ' ') && (i+len(word) == len(text) || text[i+len(word)] == ' '):
+			return i
+		}
+	}
+
+	return -1
+}
+
+// "checkout 99.9% when route eq '/checkout' good status lt 500"
+func ParseDefinition(text string) (Definition, error) {
+	matches := definitionRegex.FindStringSubmatch(strings.TrimSpace(text))
+	invalid := fmt.Errorf("invalid slo \"%s\". expected something like \"checkout 99.9%% when route eq '/checkout' good status lt 500\"", text)
+
+	if matches == nil {
+		return Definition{}, invalid
+	}
+
+	target, err := strconv.ParseFloat(matches[2], 64)
+
+	if err != nil || target <= 0 || target >= 100 {
+		return Definition{}, fmt.Errorf("invalid target \"%s%%\". it should be between 0%% and 100%%, like 99.9%%", matches[2])
+	}
+
+	good := findWord(matches[3], "good")
+
+	if good == -1 {
+		return Definition{}, invalid
+	}
+
+	definition := Definition{
+		Name:     matches[1],
+		Target:   target / 100,
+		Selector: strings.TrimSpace(matches[3][:good]),
+		Good:     strings.TrimSpace(matches[3][good+len("good"):]),
+	}
+
+	if len(definition.Selector) == 0 || len(definition.Good) == 0 {
+		return Definition{}, invalid
+	}
+
+	return definition, nil
+}
+
+type counts_t struct {
+	total int
+	bad   int
+}
+
+// Window is the burn rate of the last events of the slo
+type Window struct {
+	Window time.Duration
+	Total  int
+	Bad    int
+	// how fast the error budget is spent. 1 spends exactly the budget, 10
+	// spends it 10 times faster
+	BurnRate float64
+}
+
+// Report is the summary of an slo
+type Report struct {
+	Definition Definition
+	Total      int
+	Good       int
+	Bad        int
+	// the fraction of good events, 1 when there are no events
+	Compliance float64
+	// the fraction of the error budget still available. negative when it
+	// was spent
+	BudgetLeft float64
+	Windows    []Window
+}
+
+func (r Report) Met() bool {
+	return r.Compliance >= r.Definition.Target
+}
+
+// Tracker counts the good and the bad events of an slo
+type Tracker struct {
+	Definition Definition
+
+	windows []time.Duration
+	total   int
+	bad     int
+	// the events of each minute, within the longest window
+	minutes map[int64]*counts_t
+	last    time.Time
+	mutex   sync.Mutex
+}
+
+func CreateTracker(definition Definition, windows []time.Duration) *Tracker {
+	return &Tracker{
+		Definition: definition,
+		windows:    windows,
+		minutes:    make(map[int64]*counts_t),
+	}
+}
+
+func (t *Tracker) longest() time.Duration {
+	longest := time.Duration(0)
+
+	for _, window := range t.windows {
+		longest = max(longest, window)
+	}
+
+	return longest
+}
+
+// Add counts an event at t, which is usually the time of the log. the
+// windows end at the last event
+func (t *Tracker) Add(at time.Time, good bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.total++
+
+	if !good {
+		t.bad++
+	}
+
+	minute := at.Unix() / 60
+	counts, ok := t.minutes[minute]
+
+	if !ok {
+		counts = &counts_t{}
+		t.minutes[minute] = counts
+	}
+
+	counts.total++
+
+	if !good {
+		counts.bad++
+	}
+
+	if !at.After(t.last) {
+		return
+	}
+
+	// minutes are removed when a new one starts, so it happens once a minute at most
+	if !ok {
+		oldest := at.Add(-t.longest()).Unix() / 60
+
+		for minute := range t.minutes {
+			if minute < oldest {
+				delete(t.minutes, minute)
+			}
+		}
+	}
+
+	t.last = at
+}
+
+func burnRate(bad int, total int, target float64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return (float64(bad) / float64(total)) / (1 - target)
+}
+
+func (t *Tracker) Report() Report {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	r := Report{
+		Definition: t.Definition,
+		Total:      t.total,
+		Good:       t.total - t.bad,
+		Bad:        t.bad,
+		Compliance: 1,
+		BudgetLeft: 1,
+		Windows:    make([]Window, 0, len(t.windows)),
+	}
+
+	if t.total > 0 {
+		r.Compliance = float64(r.Good) / float64(t.total)
+		r.BudgetLeft = 1 - burnRate(t.bad, t.total, t.Definition.Target)
+	}
+
+	last := t.last.Unix() / 60
+
+	for _, window := range t.windows {
+		w := Window{Window: window}
+		// whole minutes, including the one of the last event
+		minutes := max(int64(window/time.Minute), 1)
+
+		for minute, counts := range t.minutes {
+			if minute > last-minutes {
+				w.Total += counts.total
+				w.Bad += counts.bad
+			}
+		}
+
+		w.BurnRate = burnRate(w.Bad, w.Total, t.Definition.Target)
+		r.Windows = append(r.Windows, w)
+	}
+
+	return r
+}
@@ -0,0 +1,86 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2024, 10, 10, 13, 0, 0, 0, time.UTC)
+
+func TestParseDefinition(t *testing.T) {
+	definition, err := ParseDefinition("checkout 99.9% when route eq '/checkout' good status lt 500")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "checkout", definition.Name)
+	assert.InDelta(t, 0.999, definition.Target, 1e-9)
+	assert.Equal(t, "route eq '/checkout'", definition.Selector)
+	assert.Equal(t, "status lt 500", definition.Good)
+
+	definition, err = ParseDefinition("goods 99% when path eq '/goods good' and method eq :get good status lt 500 and size gt 0")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "path eq '/goods good' and method eq :get", definition.Selector)
+	assert.Equal(t, "status lt 500 and size gt 0", definition.Good)
+}
+
+func TestParseInvalidDefinition(t *testing.T) {
+	for _, text := range []string{
+		"checkout 99.9% when route eq '/checkout'",
+		"checkout 99.9 when route eq '/checkout' good status lt 500",
+		"checkout 100% when route eq '/checkout' good status lt 500",
+		"checkout 99.9% when good status lt 500",
+		"checkout 99.9% when route eq '/checkout' good",
+	} {
+		_, err := ParseDefinition(text)
+
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestReport(t *testing.T) {
+	tracker := CreateTracker(Definition{Name: "api", Target: 0.99}, []time.Duration{time.Minute, time.Hour})
+
+	// 1% bad in the first hour, 10% bad in the last minute
+	for i := range 1000 {
+		tracker.Add(start.Add(time.Duration(i)*3*time.Second), i%100 != 0)
+	}
+
+	for i := range 100 {
+		tracker.Add(start.Add(time.Hour+time.Duration(i)*100*time.Millisecond), i%10 != 0)
+	}
+
+	r := tracker.Report()
+
+	assert.Equal(t, 1100, r.Total)
+	assert.Equal(t, 20, r.Bad)
+	assert.InDelta(t, 0.9818, r.Compliance, 0.0001)
+	assert.False(t, r.Met())
+	assert.InDelta(t, -0.818, r.BudgetLeft, 0.001)
+
+	assert.Equal(t, Window{Window: time.Minute, Total: 100, Bad: 10, BurnRate: r.Windows[0].BurnRate}, r.Windows[0])
+	assert.InDelta(t, 10, r.Windows[0].BurnRate, 0.0001)
+	// the last 60 whole minutes, without the first one
+	assert.Equal(t, 1080, r.Windows[1].Total)
+}
+
+func TestOldMinutesAreRemoved(t *testing.T) {
+	tracker := CreateTracker(Definition{Name: "api", Target: 0.99}, []time.Duration{time.Hour})
+
+	for i := range 180 {
+		tracker.Add(start.Add(time.Duration(i)*time.Minute), false)
+	}
+
+	assert.LessOrEqual(t, len(tracker.minutes), 61)
+	assert.Equal(t, 60, tracker.Report().Windows[0].Total)
+	assert.Equal(t, 180, tracker.Report().Total)
+}
+
+func TestEmptyReport(t *testing.T) {
+	r := CreateTracker(Definition{Name: "api", Target: 0.99}, DefaultWindows).Report()
+
+	assert.True(t, r.Met())
+	assert.Equal(t, 1.0, r.BudgetLeft)
+	assert.Len(t, r.Windows, 4)
+}
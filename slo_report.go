@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marcos-venicius/lfi/slo"
+	"github.com/marcos-venicius/quang"
+)
+
+type slo_t struct {
+	tracker *slo.Tracker
+
+	selector          *quang.Quang
+	selectorVariables []string
+	good              *quang.Quang
+	goodVariables     []string
+	// the good query failed on a log, which is only reported once
+	failed bool
+}
+
+// slo_report_t checks the logs against the slos of the config, for -slo
+type slo_report_t struct {
+	slos    []slo_t
+	windows []time.Duration
+	output  string
+}
+
+// "5m, 1h" -> [5m, 1h]
+func parseWindows(list string) ([]time.Duration, error) {
+	windows := make([]time.Duration, 0)
+
+	for _, text := range strings.Split(list, ",") {
+		window, err := time.ParseDuration(strings.TrimSpace(text))
+
+		if err != nil || window < time.Minute {
+			return nil, fmt.Errorf("invalid window \"%s\". it should be a duration of at least 1m, like 5m or 1h", strings.TrimSpace(text))
+		}
+
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
+
+func createSLOReport(definitions []slo.Definition, windows []time.Duration, output string) (*slo_report_t, error) {
+	report := &slo_report_t{windows: windows, output: output}
+
+	for _, definition := range definitions {
+		selector, err := compileQuery(definition.Selector)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid query of slo \"%s\": %s", definition.Name, err.Error())
+		}
+
+		good, err := compileQuery(definition.Good)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid good query of slo \"%s\": %s", definition.Name, err.Error())
+		}
+
+		report.slos = append(report.slos, slo_t{
+			tracker:           slo.CreateTracker(definition, windows),
+			selector:          selector,
+			selectorVariables: dynamicQueryVariables(definition.Selector),
+			good:              good,
+			goodVariables:     dynamicQueryVariables(definition.Good),
+		})
+	}
+
+	return report, nil
+}
+
+func (r *slo_report_t) add(log log_t) {
+	t := log.judgedTime()
+
+	for i := range r.slos {
+		s := &r.slos[i]
+
+		log.bindQueryVariables(s.selector, s.selectorVariables)
+
+		if selected, err := s.selector.Eval(); err != nil || !selected {
+			continue
+		}
+
+		log.bindQueryVariables(s.good, s.goodVariables)
+
+		good, err := s.good.Eval()
+
+		// a log the good query can't judge is not an event
+		if err != nil {
+			if !s.failed {
+				fmt.Fprintf(os.Stderr, "error: the good query of slo \"%s\" failed, the logs it fails on are not counted: %s\n", s.tracker.Definition.Name, err.Error())
+			}
+
+			s.failed = true
+			continue
+		}
+
+		s.tracker.Add(t, good)
+	}
+}
+
+// 0.999 -> 99.9%
+func displayPercent(fraction float64) string {
+	return fmt.Sprintf("%v%%", roundResult(fraction*100))
+}
+
+func sloStatus(report slo.Report) string {
+	if report.Met() {
+		return "ok"
+	}
+
+	return "breached"
+}
+
+func (r *slo_report_t) write(w io.Writer) error {
+	reports := make([]slo.Report, 0, len(r.slos))
+
+	for _, s := range r.slos {
+		reports = append(reports, s.tracker.Report())
+	}
+
+	columns := []string{"name", "target", "events", "bad", "compliance", "budget_left"}
+
+	for _, window := range r.windows {
+		columns = append(columns, "burn_"+displayDuration(window))
+	}
+
+	columns = append(columns, "status")
+
+	switch r.output {
+	case "json":
+		rows := make([]map[string]any, 0, len(reports))
+
+		for _, report := range reports {
+			burnRates := make(map[string]any)
+
+			for _, window := range report.Windows {
+				burnRates[displayDuration(window.Window)] = map[string]any{
+					"events":    window.Total,
+					"bad":       window.Bad,
+					"burn_rate": roundResult(window.BurnRate),
+				}
+			}
+
+			rows = append(rows, map[string]any{
+				"name":        report.Definition.Name,
+				"target":      report.Definition.Target,
+				"events":      report.Total,
+				"good":        report.Good,
+				"bad":         report.Bad,
+				"compliance":  report.Compliance,
+				"budget_left": report.BudgetLeft,
+				"windows":     burnRates,
+				"status":      sloStatus(report),
+			})
+		}
+
+		return json.NewEncoder(w).Encode(rows)
+	case "csv":
+		writer := csv.NewWriter(w)
+
+		writer.Write(columns)
+
+		for _, report := range reports {
+			row := []string{report.Definition.Name, fmt.Sprint(report.Definition.Target), fmt.Sprint(report.Total), fmt.Sprint(report.Bad), fmt.Sprint(report.Compliance), fmt.Sprint(report.BudgetLeft)}
+
+			for _, window := range report.Windows {
+				row = append(row, displayResult(roundResult(window.BurnRate)))
+			}
+
+			writer.Write(append(row, sloStatus(report)))
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "%s\n", strings.ToUpper(strings.Join(columns, "\t")))
+
+	for _, report := range reports {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%d\t%s\t%s\t", report.Definition.Name, displayPercent(report.Definition.Target), report.Total, report.Bad, displayPercent(report.Compliance), displayPercent(report.BudgetLeft))
+
+		for _, window := range report.Windows {
+			fmt.Fprintf(writer, "%s\t", displayResult(roundResult(window.BurnRate)))
+		}
+
+		fmt.Fprintf(writer, "%s\n", sloStatus(report))
+	}
+
+	return writer.Flush()
+}
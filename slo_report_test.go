@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcos-venicius/lfi/slo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSLOReport(t *testing.T) {
+	definitions := []slo.Definition{
+		{Name: "api", Target: 0.99, Selector: "method eq :get", Good: "status lt 500"},
+		// the size can't be compared with a string, so every log fails
+		{Name: "broken", Target: 0.99, Selector: "method eq :get", Good: "size gt 'a'"},
+	}
+
+	report, err := createSLOReport(definitions, []time.Duration{time.Hour}, "table")
+
+	assert.Nil(t, err)
+
+	report.add(testLog(t))
+
+	api := report.slos[0].tracker.Report()
+	broken := report.slos[1].tracker.Report()
+
+	assert.Equal(t, 1, api.Total)
+	assert.Equal(t, 0, api.Bad)
+	assert.Equal(t, 0, broken.Total)
+	assert.True(t, report.slos[1].failed)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/marcos-venicius/quang"
+)
+
+const logTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+var templateFuncs = template.FuncMap{
+	"humanize":   humanizeBytes,
+	"pad":        padRight,
+	"lpad":       padLeft,
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"timeformat": formatLogTime,
+	"urldecode":  urlDecode,
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("log").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case quang.IntegerType:
+		return float64(v), nil
+	case quang.FloatType:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+
+	return 0, fmt.Errorf("cannot use %v as a number", value)
+}
+
+// humanize 1536 -> "1.5 KB"
+func humanizeBytes(value any) (string, error) {
+	n, err := toFloat(value)
+
+	if err != nil {
+		return "", err
+	}
+
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	unit := 0
+
+	for n >= 1024 && unit < len(units)-1 {
+		n /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", int64(n), units[unit]), nil
+	}
+
+	return fmt.Sprintf("%.1f %s", n, units[unit]), nil
+}
+
+// pad 10 .ip -> ".ip" followed by spaces until it has 10 chars
+func padRight(width int, value any) string {
+	return fmt.Sprintf("%-*v", width, value)
+}
+
+// lpad 10 .ip -> spaces followed by ".ip" until it has 10 chars
+func padLeft(width int, value any) string {
+	return fmt.Sprintf("%*v", width, value)
+}
+
+// timeformat "2006-01-02 15:04" .time
+func formatLogTime(layout string, value string) (string, error) {
+	parsed, err := time.Parse(logTimeLayout, value)
+
+	if err != nil {
+		return "", err
+	}
+
+	return parsed.Format(layout), nil
+}
+
+func urlDecode(value string) string {
+	decoded, err := url.QueryUnescape(value)
+
+	if err != nil {
+		return value
+	}
+
+	return decoded
+}
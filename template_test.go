@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/marcos-venicius/quang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanizeBytes(t *testing.T) {
+	cases := map[any]string{
+		0:                        "0 B",
+		quang.IntegerType(1023):  "1023 B",
+		quang.IntegerType(1536):  "1.5 KB",
+		int64(5 * 1024 * 1024):   "5.0 MB",
+		quang.FloatType(1 << 40): "1.0 TB",
+		"2048":                   "2.0 KB",
+	}
+
+	for value, expected := range cases {
+		text, err := humanizeBytes(value)
+
+		assert.Nil(t, err, value)
+		assert.Equal(t, expected, text, value)
+	}
+
+	_, err := humanizeBytes("big")
+
+	assert.NotNil(t, err)
+
+	_, err = humanizeBytes(true)
+
+	assert.NotNil(t, err)
+}
+
+func TestPad(t *testing.T) {
+	assert.Equal(t, "GET   ", padRight(6, "GET"))
+	assert.Equal(t, "   GET", padLeft(6, "GET"))
+	assert.Equal(t, "200", padLeft(2, quang.IntegerType(200)))
+}
+
+func TestFormatLogTime(t *testing.T) {
+	text, err := formatLogTime("2006-01-02 15:04", "10/Oct/2024:13:55:36 +0000")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "2024-10-10 13:55", text)
+
+	_, err = formatLogTime("2006-01-02", "yesterday")
+
+	assert.NotNil(t, err)
+}
+
+func TestURLDecode(t *testing.T) {
+	assert.Equal(t, "/search?q=a b", urlDecode("/search?q=a%20b"))
+	assert.Equal(t, "100%", urlDecode("100%"))
+}
+
+func TestDisplayLogsBasedOnTemplate(t *testing.T) {
+	log := log_t{ip: "10.0.0.1", method: http_get_atom, statusCode: 200, size: 1536}
+
+	tmpl, err := parseTemplate(`{{.ip}} {{.method}} {{humanize .size}}`)
+
+	assert.Nil(t, err)
+
+	var output bytes.Buffer
+
+	assert.Nil(t, displayLogsBasedOnTemplate(&output, tmpl, log))
+	assert.Equal(t, "10.0.0.1 GET 1.5 KB\n", output.String())
+
+	// a template failing in the middle prints nothing
+	tmpl, err = parseTemplate(`{{.ip}} {{humanize .agent}}`)
+
+	assert.Nil(t, err)
+
+	output.Reset()
+
+	assert.NotNil(t, displayLogsBasedOnTemplate(&output, tmpl, log))
+	assert.Empty(t, output.String())
+}
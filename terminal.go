@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// keys that are sent as escape sequences
+const (
+	key_up        = "\x1b[A"
+	key_down      = "\x1b[B"
+	key_page_up   = "\x1b[5~"
+	key_page_down = "\x1b[6~"
+	key_home      = "\x1b[H"
+	key_end       = "\x1b[F"
+	key_escape    = "\x1b"
+)
+
+// a full screen terminal, used by subcommands like "lfi top"
+type terminal_t struct {
+	tty   *os.File
+	state *term.State
+}
+
+// stdin may be the logs, so the screen and the keys are the ones of /dev/tty
+func openTerminal() (*terminal_t, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := term.MakeRaw(int(tty.Fd()))
+
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+
+	// alternate screen without cursor, like htop
+	io.WriteString(tty, "\x1b[?1049h\x1b[?25l\x1b[2J")
+
+	return &terminal_t{tty: tty, state: state}, nil
+}
+
+func (t *terminal_t) close() {
+	io.WriteString(t.tty, "\x1b[?25h\x1b[?1049l")
+
+	term.Restore(int(t.tty.Fd()), t.state)
+
+	t.tty.Close()
+}
+
+// deferred in the goroutines that read the logs. the deferred close of the
+// terminal doesn't run for their panics, which would leave it in raw mode
+func (t *terminal_t) recoverPanic() {
+	if r := recover(); r != nil {
+		t.close()
+		fmt.Fprintf(os.Stderr, "error: %v\n", r)
+		os.Exit(1)
+	}
+}
+
+func (t *terminal_t) size() (int, int) {
+	width, height, err := term.GetSize(int(t.tty.Fd()))
+
+	if err != nil {
+		return 80, 24
+	}
+
+	return width, height
+}
+
+// every read of the terminal, which is a single key or a whole escape
+// sequence like key_up
+func (t *terminal_t) keys() chan string {
+	keys := make(chan string)
+
+	go func() {
+		buffer := make([]byte, 32)
+
+		for {
+			n, err := t.tty.Read(buffer)
+
+			if err != nil || n == 0 {
+				close(keys)
+				return
+			}
+
+			keys <- string(buffer[:n])
+		}
+	}()
+
+	return keys
+}
+
+// replaces the screen with lines, one per row
+func (t *terminal_t) draw(lines []string) {
+	var builder strings.Builder
+
+	builder.WriteString("\x1b[H")
+
+	for i, line := range lines {
+		builder.WriteString(line)
+		builder.WriteString("\x1b[K")
+
+		if i < len(lines)-1 {
+			builder.WriteString("\r\n")
+		}
+	}
+
+	builder.WriteString("\x1b[J")
+
+	io.WriteString(t.tty, builder.String())
+}
+
+// cuts s to width columns, or pads it with spaces
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	length := utf8.RuneCountInString(s)
+
+	if length > width {
+		runes := []rune(s)
+
+		if width == 1 {
+			return string(runes[:1])
+		}
+
+		return string(runes[:width-1]) + "…"
+	}
+
+	return s + strings.Repeat(" ", width-length)
+}
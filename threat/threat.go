@@ -0,0 +1,295 @@
+package threat
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+
+	"github.com/marcos-venicius/lfi/lru"
+)
+
+// how many user agents are remembered by the detector
+const CACHE_SIZE = 4096
+
+//go:embed rules.txt
+var defaultRules []byte
+
+type Match struct {
+	// the kind of attack, like "sqli", "xss" or "probe". empty when nothing matched
+	Category string
+	Rule     string
+}
+
+type rule_t struct {
+	category string
+	name     string
+	pattern  *regexp.Regexp
+	// lowercase strings where at least one of them is in every match of the
+	// pattern, so most values are skipped without running the regex. the
+	// regex always runs when it's empty
+	triggers []string
+}
+
+// Detector finds attack signatures in requests
+type Detector struct {
+	resource []rule_t
+	agent    []rule_t
+	// the user agents repeat a lot, so their matches are remembered
+	agents *lru.Cache[string, Match]
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// triggers finds the literals required by the pattern. it returns nil when
+// the pattern can match without any of them
+func triggers(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		literal := strings.ToLower(string(re.Rune))
+
+		if !isASCII(literal) {
+			return nil
+		}
+
+		return []string{literal}
+	case syntax.OpCapture, syntax.OpPlus:
+		return triggers(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return triggers(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// the child with the longest shortest literal is the most selective
+		var best []string
+		bestLength := 0
+
+		for _, sub := range re.Sub {
+			literals := triggers(sub)
+
+			if literals == nil {
+				continue
+			}
+
+			shortest := len(literals[0])
+
+			for _, literal := range literals {
+				shortest = min(shortest, len(literal))
+			}
+
+			if shortest > bestLength {
+				best = literals
+				bestLength = shortest
+			}
+		}
+
+		return best
+	case syntax.OpAlternate:
+		literals := make([]string, 0, len(re.Sub))
+
+		for _, sub := range re.Sub {
+			found := triggers(sub)
+
+			if found == nil {
+				return nil
+			}
+
+			literals = append(literals, found...)
+		}
+
+		return literals
+	}
+
+	return nil
+}
+
+func parseTriggers(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+
+	if err != nil {
+		return nil
+	}
+
+	return triggers(re.Simplify())
+}
+
+// Parse a rules file. every non empty line that does not start with "#"
+// is "<target> <category> <name>  <pattern>", where target is resource,
+// agent or any.
+func Parse(content []byte) (*Detector, error) {
+	d := Detector{agents: lru.Create[string, Match](CACHE_SIZE)}
+
+	for number, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("line %d: expected \"<target> <category> <name>  <pattern>\"", number+1)
+		}
+
+		target, category, name := fields[0], fields[1], fields[2]
+
+		// the pattern is the rest of the line, spaces included
+		pattern := line
+
+		for _, field := range fields[:3] {
+			pattern = strings.TrimSpace(strings.TrimPrefix(pattern, field))
+		}
+
+		re, err := regexp.Compile(pattern)
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern: %s", number+1, err.Error())
+		}
+
+		rule := rule_t{category: category, name: name, pattern: re, triggers: parseTriggers(pattern)}
+
+		switch target {
+		case "resource":
+			d.resource = append(d.resource, rule)
+		case "agent":
+			d.agent = append(d.agent, rule)
+		case "any":
+			d.resource = append(d.resource, rule)
+			d.agent = append(d.agent, rule)
+		default:
+			return nil, fmt.Errorf("line %d: invalid rule target \"%s\"", number+1, target)
+		}
+	}
+
+	return &d, nil
+}
+
+func Load(filePath string) (*Detector, error) {
+	content, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := Parse(content)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err.Error())
+	}
+
+	return d, nil
+}
+
+// Default returns a detector using the rules embedded in the binary
+func Default() *Detector {
+	d, err := Parse(defaultRules)
+
+	if err != nil {
+		panic("unreacheable: invalid embedded threat rules: " + err.Error())
+	}
+
+	return d
+}
+
+// Decode url decodes the resource twice, so double encoded attacks like
+// %252e%252e%252f are also found. invalid escapes are kept as they are
+func Decode(resource string) string {
+	for range 2 {
+		decoded := unescape(resource)
+
+		if decoded == resource {
+			break
+		}
+
+		resource = decoded
+	}
+
+	return resource
+}
+
+// decodes each %XX, and each +, on its own. url.QueryUnescape gives up on
+// the whole value at the first invalid escape, like the % of "100%' or 1=1"
+func unescape(value string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '%' && i+2 < len(value):
+			if n, err := strconv.ParseUint(value[i+1:i+3], 16, 8); err == nil {
+				builder.WriteByte(byte(n))
+				i += 2
+			} else {
+				builder.WriteByte(value[i])
+			}
+		case value[i] == '+':
+			builder.WriteByte(' ')
+		default:
+			builder.WriteByte(value[i])
+		}
+	}
+
+	return builder.String()
+}
+
+func (r rule_t) triggered(lower string) bool {
+	if len(r.triggers) == 0 {
+		return true
+	}
+
+	for _, trigger := range r.triggers {
+		if strings.Contains(lower, trigger) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func find(rules []rule_t, value string) (Match, bool) {
+	if len(value) == 0 {
+		return Match{}, false
+	}
+
+	lower := strings.ToLower(value)
+
+	for _, rule := range rules {
+		if rule.triggered(lower) && rule.pattern.MatchString(value) {
+			return Match{Category: rule.category, Rule: rule.name}, true
+		}
+	}
+
+	return Match{}, false
+}
+
+// Detect returns the first rule matching the agent or the decoded resource
+func (d *Detector) Detect(resource string, agent string) Match {
+	match, ok := d.agents.Get(agent)
+
+	if !ok {
+		match, _ = find(d.agent, agent)
+
+		d.agents.Add(agent, match)
+	}
+
+	if len(match.Category) > 0 {
+		return match
+	}
+
+	if match, ok = find(d.resource, Decode(resource)); ok {
+		return match
+	}
+
+	return Match{}
+}
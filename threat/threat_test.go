@@ -0,0 +1,120 @@
+package threat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const browser = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+func TestDetect(t *testing.T) {
+	d := Default()
+
+	tests := []struct {
+		resource string
+		agent    string
+		expected Match
+	}{
+		{"/index.php?page=../../../../etc/passwd", browser, Match{"lfi", "etc_passwd"}},
+		{"/download?file=%2e%2e%2f%2e%2e%2fapp%2fsecrets.txt", browser, Match{"traversal", "dot_dot_slash"}},
+		{"/download?file=%252e%252e%252fsecrets", browser, Match{"traversal", "dot_dot_slash"}},
+		{"/index.php?page=php://filter/convert.base64-encode/resource=index", browser, Match{"lfi", "php_wrapper"}},
+		{"/view?file=report.pdf%00.html", browser, Match{"lfi", "null_byte"}},
+		{"/products?id=1%20UNION%20SELECT%20username,password%20FROM%20users", browser, Match{"sqli", "union_select"}},
+		{"/products?id=1+union+all+select+1,2", browser, Match{"sqli", "union_select"}},
+		{"/login?user=admin'%20OR%20'1'='1", browser, Match{"sqli", "tautology"}},
+		{"/items?id=1;SELECT%20pg_sleep(10)", browser, Match{"sqli", "time_based"}},
+		{"/login?user=admin'--", browser, Match{"sqli", "comment_escape"}},
+		{"/search?q=<script>alert(1)</script>", browser, Match{"xss", "script_tag"}},
+		{"/search?q=%3Cimg%20src%3Dx%20onerror%3Dalert(1)%3E", browser, Match{"xss", "event_handler"}},
+		{"/redirect?to=javascript:alert(document.cookie)", browser, Match{"xss", "javascript_uri"}},
+		{"/ping?host=127.0.0.1;cat%20/etc/hosts", browser, Match{"lfi", "etc_passwd"}},
+		{"/ping?host=127.0.0.1%7C%7Cwhoami", browser, Match{"cmdi", "command_chain"}},
+		{"/ping?host=$(id)", browser, Match{"cmdi", "subshell"}},
+		{"/cgi-bin/status", "() { :; }; /bin/bash -c 'cat /etc/passwd'", Match{"cmdi", "shellshock"}},
+		{"/.env", browser, Match{"probe", "dotenv"}},
+		{"/app/.env.production", browser, Match{"probe", "dotenv"}},
+		{"/.git/config", browser, Match{"probe", "git"}},
+		{"/wp-admin/setup-config.php", browser, Match{"probe", "wordpress"}},
+		{"/phpmyadmin/index.php", browser, Match{"probe", "phpmyadmin"}},
+		{"/", "sqlmap/1.7.2#stable (https://sqlmap.org)", Match{"scanner", "scanner_agent"}},
+		{"/", "Mozilla/5.00 (Nikto/2.1.6) (Evasions:None) (Test:000001)", Match{"scanner", "scanner_agent"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, d.Detect(test.resource, test.agent), test.resource)
+	}
+}
+
+func TestBenignRequests(t *testing.T) {
+	d := Default()
+
+	for _, resource := range []string{
+		"/",
+		"/api/users/42?id=5&sort=name",
+		"/search?q=rock+and+roll&page=2",
+		"/blog/2024/01/how-to-select-a-union-representative",
+		"/docs/getting-started.html#install",
+		"/static/js/app.8f3e2a.js",
+		"/products?category=shoes&size=42&color=black%20%26%20white",
+		"/environment/status",
+		"/users?name=O'Brien",
+		"/files/report..final.pdf",
+	} {
+		assert.Equal(t, Match{}, d.Detect(resource, browser), resource)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cases := map[string]string{
+		"/a%20b/%2e%2e/":            "/a b/../",
+		"%252e%252e%252f":           "../",
+		"/100%":                     "/100%",
+		"/?q=1+2":                   "/?q=1 2",
+		"/?q=100%'%20or%201=1":      "/?q=100%' or 1=1",
+		"/?p=%zz%2e%2e%2f%":         "/?p=%zz../%",
+		"/?p=%%32%65%%32%65%%32%66": "/?p=../",
+	}
+
+	for resource, expected := range cases {
+		assert.Equal(t, expected, Decode(resource), resource)
+	}
+}
+
+func TestDetectWithInvalidEscapes(t *testing.T) {
+	d := Default()
+
+	assert.Equal(t, "xss", d.Detect("/search?discount=100%&q=%3Cscript%3Ealert(1)%3C/script%3E", browser).Category)
+}
+
+func TestParse(t *testing.T) {
+	d, err := Parse([]byte("# comment\n\nresource  custom  secret_path  ^/internal/\nagent  scanner  my_scanner  (?i)evilbot\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, Match{"custom", "secret_path"}, d.Detect("/internal/metrics", browser))
+	assert.Equal(t, Match{"scanner", "my_scanner"}, d.Detect("/", "EvilBot/1.0"))
+	assert.Equal(t, Match{}, d.Detect("/.env", browser))
+
+	_, err = Parse([]byte("header  sqli  x  y"))
+
+	assert.Equal(t, "line 1: invalid rule target \"header\"", err.Error())
+
+	_, err = Parse([]byte("resource  sqli  broken  ("))
+
+	assert.NotNil(t, err)
+
+	_, err = Parse([]byte("resource  sqli  missing"))
+
+	assert.Equal(t, "line 1: expected \"<target> <category> <name>  <pattern>\"", err.Error())
+}
+
+func TestTriggers(t *testing.T) {
+	assert.Equal(t, []string{"sqlmap", "nikto"}, parseTriggers(`(?i)\b(sqlmap|nikto)\b`))
+	assert.Equal(t, []string{"passwd", "shadow"}, parseTriggers(`(?i)/etc/(passwd|shadow)`))
+	assert.Equal(t, []string{"script"}, parseTriggers(`(?i)<\s*script`))
+	assert.Equal(t, []string{"/.ds_store"}, parseTriggers(`/\.DS_Store`))
+	assert.Nil(t, parseTriggers(`\d+`))
+	assert.Nil(t, parseTriggers(`(a|\d)`))
+	assert.Nil(t, parseTriggers(`(abc)?`))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+var threatFieldRegex = regexp.MustCompile(`\bthreat(_rule)?\b`)
+
+// detecting the threats is the slowest part of parsing a log, so it's only
+// done when a text, like the query or the format, mentions threat or
+// threat_rule. a false mention only costs the detection
+func mentionsThreat(texts ...string) bool {
+	for _, text := range texts {
+		if threatFieldRegex.MatchString(text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type threat_ip_t struct {
+	ip       string
+	requests int
+	threats  int
+	// how many threats of each category and rule
+	categories map[string]int
+	rules      map[string]int
+	// the times of the first and the last threats
+	first string
+	last  string
+}
+
+// threat_report_t counts the threats of each ip, for -threat-report
+type threat_report_t struct {
+	top    int
+	output string
+
+	ips   map[string]*threat_ip_t
+	mutex sync.Mutex
+}
+
+func createThreatReport(top int, output string) *threat_report_t {
+	return &threat_report_t{
+		top:    top,
+		output: output,
+		ips:    make(map[string]*threat_ip_t),
+	}
+}
+
+func (r *threat_report_t) add(log log_t) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ip, ok := r.ips[log.ip]
+
+	if !ok {
+		ip = &threat_ip_t{ip: log.ip, categories: make(map[string]int), rules: make(map[string]int)}
+		r.ips[log.ip] = ip
+	}
+
+	ip.requests++
+
+	if len(log.threat.Category) == 0 {
+		return
+	}
+
+	ip.threats++
+	ip.categories[log.threat.Category]++
+	ip.rules[log.threat.Rule]++
+
+	if len(ip.first) == 0 {
+		ip.first = log.time
+	}
+
+	ip.last = log.time
+}
+
+// the keys with the biggest counts first
+func byCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+// "sqli:3 xss:1"
+func (ip *threat_ip_t) categoriesDisplay() string {
+	parts := make([]string, 0, len(ip.categories))
+
+	for _, category := range byCount(ip.categories) {
+		parts = append(parts, fmt.Sprintf("%s:%d", category, ip.categories[category]))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (ip *threat_ip_t) topRule() string {
+	return byCount(ip.rules)[0]
+}
+
+// the ips with threats, with the most threats first, limited to the top n
+func (r *threat_report_t) sorted() []*threat_ip_t {
+	ips := make([]*threat_ip_t, 0)
+
+	for _, ip := range r.ips {
+		if ip.threats > 0 {
+			ips = append(ips, ip)
+		}
+	}
+
+	sort.Slice(ips, func(i, j int) bool {
+		if ips[i].threats != ips[j].threats {
+			return ips[i].threats > ips[j].threats
+		}
+
+		return ips[i].ip < ips[j].ip
+	})
+
+	if r.top > 0 && len(ips) > r.top {
+		ips = ips[:r.top]
+	}
+
+	return ips
+}
+
+func (r *threat_report_t) write(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ips := r.sorted()
+	columns := []string{"ip", "requests", "threats", "categories", "top_rule", "first", "last"}
+
+	switch r.output {
+	case "json":
+		rows := make([]map[string]any, 0, len(ips))
+
+		for _, ip := range ips {
+			rows = append(rows, map[string]any{
+				"ip":         ip.ip,
+				"requests":   ip.requests,
+				"threats":    ip.threats,
+				"categories": ip.categories,
+				"rules":      ip.rules,
+				"first":      ip.first,
+				"last":       ip.last,
+			})
+		}
+
+		return json.NewEncoder(w).Encode(rows)
+	case "csv":
+		writer := csv.NewWriter(w)
+
+		writer.Write(columns)
+
+		for _, ip := range ips {
+			writer.Write([]string{ip.ip, fmt.Sprint(ip.requests), fmt.Sprint(ip.threats), ip.categoriesDisplay(), ip.topRule(), ip.first, ip.last})
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "%s\n", strings.ToUpper(strings.Join(columns, "\t")))
+
+	for _, ip := range ips {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", ip.ip, ip.requests, ip.threats, ip.categoriesDisplay(), ip.topRule(), ip.first, ip.last)
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMentionsThreat(t *testing.T) {
+	for _, text := range []string{"threat ne ''", "%ip %threat", "{{.threat_rule}}", "ip,threat"} {
+		assert.True(t, mentionsThreat("", text), text)
+	}
+
+	for _, text := range []string{"", "status gte 500", "%ip %resource", "{{.threats}}", "threat_report"} {
+		assert.False(t, mentionsThreat(text), text)
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/marcos-venicius/lfi/useragent"
+	"github.com/marcos-venicius/quang"
+	"golang.org/x/term"
+)
+
+// seconds of the rolling requests per second sparkline
+const rateWindow = 60
+
+const (
+	sort_by_count = iota
+	sort_by_errors
+	sort_by_bytes
+	sort_by_count_of_columns
+)
+
+var sortColumns = []string{"count", "errors", "bytes"}
+var columnWidths = []int{10, 10, 12}
+
+type top_row_t struct {
+	key    string
+	count  int
+	errors int
+	bytes  int64
+}
+
+type dashboard_t struct {
+	query            string
+	q                *quang.Quang
+	dynamicVariables []string
+
+	// every parsed log, matching the query or not
+	seen    int
+	matched int
+	bytes   int64
+	classes map[string]int
+	ips     map[string]*top_row_t
+	routes  map[string]*top_row_t
+	agents  map[string]*top_row_t
+
+	// matching logs per second, the last item is the current second
+	rates  [rateWindow]int
+	second int64
+	reset  time.Time
+
+	paused  bool
+	sort    int
+	editing bool
+	input   []rune
+	// the last error of the query, shown in the status line
+	err  string
+	done bool
+
+	mutex sync.Mutex
+}
+
+func createDashboard(query string, q *quang.Quang) *dashboard_t {
+	d := &dashboard_t{}
+
+	d.setQuery(query, q)
+
+	return d
+}
+
+// the counters start over when the query changes
+func (d *dashboard_t) setQuery(query string, q *quang.Quang) {
+	d.query = query
+	d.q = q
+	d.dynamicVariables = dynamicQueryVariables(query)
+	d.seen = 0
+	d.matched = 0
+	d.bytes = 0
+	d.classes = make(map[string]int)
+	d.ips = make(map[string]*top_row_t)
+	d.routes = make(map[string]*top_row_t)
+	d.agents = make(map[string]*top_row_t)
+	d.rates = [rateWindow]int{}
+	d.second = time.Now().Unix()
+	d.reset = time.Now()
+	d.err = ""
+}
+
+// moves the rates window to the current second
+func (d *dashboard_t) advance(now int64) {
+	elapsed := now - d.second
+
+	if elapsed <= 0 {
+		return
+	}
+
+	if elapsed >= rateWindow {
+		d.rates = [rateWindow]int{}
+	} else {
+		copy(d.rates[:], d.rates[elapsed:])
+
+		for i := rateWindow - int(elapsed); i < rateWindow; i++ {
+			d.rates[i] = 0
+		}
+	}
+
+	d.second = now
+}
+
+func agentLabel(agent useragent.Agent, userAgent string) string {
+	switch {
+	case agent.Bot:
+		return "bot " + agent.Browser
+	case len(agent.Browser) == 0:
+		return userAgent
+	case len(agent.OS) == 0:
+		return agent.Browser
+	}
+
+	return agent.Browser + " / " + agent.OS
+}
+
+func countRow(rows map[string]*top_row_t, key string, log log_t) {
+	row, ok := rows[key]
+
+	if !ok {
+		row = &top_row_t{key: key}
+		rows[key] = row
+	}
+
+	row.count++
+	row.bytes += int64(log.size)
+
+	if log.statusCode >= 400 {
+		row.errors++
+	}
+}
+
+func (d *dashboard_t) add(log log_t) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.seen++
+
+	log.bindQueryVariables(d.q, d.dynamicVariables)
+
+	show, err := d.q.Eval()
+
+	if err != nil {
+		d.err = err.Error()
+	}
+
+	if !show {
+		return
+	}
+
+	d.advance(time.Now().Unix())
+
+	d.matched++
+	d.bytes += int64(log.size)
+	d.rates[rateWindow-1]++
+	d.classes[statusClass(log.statusCode)]++
+
+	countRow(d.ips, log.ip, log)
+	countRow(d.routes, log.route, log)
+	countRow(d.agents, agentLabel(log.agent, log.userAgent), log)
+}
+
+func (d *dashboard_t) sorted(rows map[string]*top_row_t, n int) []*top_row_t {
+	sorted := make([]*top_row_t, 0, len(rows))
+
+	for _, row := range rows {
+		sorted = append(sorted, row)
+	}
+
+	value := func(row *top_row_t) int64 {
+		switch d.sort {
+		case sort_by_errors:
+			return int64(row.errors)
+		case sort_by_bytes:
+			return row.bytes
+		}
+
+		return int64(row.count)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if value(sorted[i]) != value(sorted[j]) {
+			return value(sorted[i]) > value(sorted[j])
+		}
+
+		return sorted[i].key < sorted[j].key
+	})
+
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+
+	return sorted
+}
+
+func displayBytes(n int64) string {
+	display, _ := humanizeBytes(n)
+
+	return display
+}
+
+func (d *dashboard_t) table(title string, rows map[string]*top_row_t, width int, height int) []string {
+	lines := make([]string, 0, height)
+	keyWidth := max(width-39, 10)
+
+	header := fit(title, keyWidth)
+
+	// the sorted column is marked with ▼
+	for i, column := range sortColumns {
+		name := strings.ToUpper(column) + " "
+
+		if i == d.sort {
+			name = strings.ToUpper(column) + "▼"
+		}
+
+		header += strings.Repeat(" ", columnWidths[i]-utf8.RuneCountInString(name)+1) + name
+	}
+
+	lines = append(lines, "\x1b[7m"+fit(header, width)+"\x1b[0m")
+
+	for _, row := range d.sorted(rows, height-1) {
+		lines = append(lines, fit(fit(row.key, keyWidth)+fmt.Sprintf("%10d %10d %12s ", row.count, row.errors, displayBytes(row.bytes)), width))
+	}
+
+	return lines
+}
+
+// the lines of the screen
+func (d *dashboard_t) render(width int, height int) []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+
+	d.advance(now.Unix())
+
+	lines := make([]string, 0, height)
+
+	status := ""
+
+	if d.paused {
+		status = "  [paused]"
+	}
+
+	query := d.query
+
+	if len(query) == 0 {
+		query = "(everything)"
+	}
+
+	lines = append(lines, fmt.Sprintf("\x1b[1mlfi top\x1b[0m  %s  query: %s%s", now.Format(time.TimeOnly), query, status))
+
+	// the current second is not over yet, so the rates use the complete ones
+	complete := d.rates[:rateWindow-1]
+	seconds := min(int(now.Sub(d.reset).Seconds()), len(complete))
+	sum, peak := 0, 0
+
+	for _, rate := range complete[len(complete)-max(seconds, 1):] {
+		sum += rate
+		peak = max(peak, rate)
+	}
+
+	lines = append(lines, fmt.Sprintf("requests %d  matched %d  bytes %s  req/s %d  avg %.1f/s  peak %d/s", d.seen, d.matched, displayBytes(d.bytes), complete[len(complete)-1], float64(sum)/float64(max(seconds, 1)), peak))
+
+	classes := make([]string, 0, 6)
+
+	for _, class := range []string{"1xx", "2xx", "3xx", "4xx", "5xx"} {
+		count := d.classes[class]
+		classes = append(classes, fmt.Sprintf("%s %d (%.1f%%)", class, count, float64(count)*100/float64(max(d.matched, 1))))
+	}
+
+	lines = append(lines, strings.Join(classes, "  "))
+	lines = append(lines, sparkline(d.rates[:])+fmt.Sprintf("  last %ds", rateWindow))
+
+	if len(d.err) > 0 {
+		lines = append(lines, "\x1b[31m"+d.err+"\x1b[0m")
+	} else {
+		lines = append(lines, "")
+	}
+
+	// the tables share what is left of the screen, but the footer
+	tableHeight := max((height-len(lines)-1)/3, 2)
+
+	lines = append(lines, d.table("IP", d.ips, width, tableHeight)...)
+	lines = append(lines, d.table("ROUTE", d.routes, width, tableHeight)...)
+	lines = append(lines, d.table("USER AGENT", d.agents, width, tableHeight)...)
+
+	if len(lines) > height-1 {
+		lines = lines[:height-1]
+	}
+
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	if d.editing {
+		lines = append(lines, "query: "+string(d.input)+"█")
+	} else {
+		lines = append(lines, fmt.Sprintf("\x1b[7m p \x1b[0m pause  \x1b[7m s \x1b[0m sort by %s  \x1b[7m / \x1b[0m query  \x1b[7m r \x1b[0m reset  \x1b[7m q \x1b[0m quit", sortColumns[d.sort]))
+	}
+
+	return lines
+}
+
+// handles a key pressed in the terminal
+func (d *dashboard_t) key(key string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.editing {
+		switch key {
+		case "\r", "\n":
+			query := string(d.input)
+			q, err := compileQuery(query)
+
+			if err != nil {
+				d.err = err.Error()
+			} else {
+				d.setQuery(query, q)
+			}
+
+			d.editing = false
+		case key_escape:
+			d.editing = false
+		case "\x7f", "\b":
+			if len(d.input) > 0 {
+				d.input = d.input[:len(d.input)-1]
+			}
+		case "\x03":
+			d.done = true
+		default:
+			if key[0] >= ' ' {
+				d.input = append(d.input, []rune(key)...)
+			}
+		}
+
+		return
+	}
+
+	switch key {
+	case "p", " ":
+		d.paused = !d.paused
+	case "s":
+		d.sort = (d.sort + 1) % sort_by_count_of_columns
+	case "/":
+		d.editing = true
+		d.input = []rune(d.query)
+	case "r":
+		d.setQuery(d.query, d.q)
+	case "q", "\x03":
+		d.done = true
+	}
+}
+
+func runTop(args []string) int {
+	flags := flag.NewFlagSet("top", flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage of lfi top: lfi top [flags] [file]\n\nshows a live dashboard of the logs of stdin, or of the lines appended to file. the query can be changed with / while running\n\n")
+		flags.PrintDefaults()
+	}
+
+	common := addLogFlags(flags)
+	refresh := flags.Duration("interval", time.Second, "how often the dashboard is redrawn")
+
+	flags.Parse(args)
+
+	configs, q, _, err := common.load()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		return 1
+	}
+
+	if *refresh < 100*time.Millisecond {
+		fmt.Fprintln(os.Stderr, "error: -interval should be at least 100ms")
+		return 1
+	}
+
+	var input io.Reader = os.Stdin
+
+	switch flags.NArg() {
+	case 0:
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Fprintln(os.Stderr, "error: lfi top needs logs. pipe them to stdin, like \"tail -f access.log | lfi top\", or give a file to follow")
+			return 1
+		}
+	case 1:
+		follower, err := followFile(flags.Arg(0))
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			return 1
+		}
+
+		defer follower.Close()
+
+		input = follower
+	default:
+		flags.Usage()
+		return 1
+	}
+
+	terminal, err := openTerminal()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: lfi top needs a terminal: %s\n", err.Error())
+		return 1
+	}
+
+	defer terminal.close()
+
+	dashboard := createDashboard(*common.query, q)
+
+	logs := make(chan []byte, 1024)
+
+	go func() {
+		readLines(input, logs)
+		close(logs)
+	}()
+
+	go func() {
+		defer terminal.recoverPanic()
+
+		for line := range logs {
+			if log, err := parseKongLogLine(string(line), *common.breakParamsOut, configs); err == nil {
+				dashboard.add(log)
+			}
+		}
+	}()
+
+	keys := terminal.keys()
+
+	ticker := time.NewTicker(*refresh)
+	defer ticker.Stop()
+
+	draw := func(force bool) {
+		width, height := terminal.size()
+
+		dashboard.mutex.Lock()
+		paused := dashboard.paused
+		dashboard.mutex.Unlock()
+
+		if force || !paused {
+			terminal.draw(dashboard.render(width, height))
+		}
+	}
+
+	draw(true)
+
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return 0
+			}
+
+			dashboard.key(key)
+
+			dashboard.mutex.Lock()
+			done := dashboard.done
+			dashboard.mutex.Unlock()
+
+			if done {
+				return 0
+			}
+
+			draw(true)
+		case <-ticker.C:
+			draw(false)
+		}
+	}
+}
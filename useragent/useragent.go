@@ -0,0 +1,189 @@
+package useragent
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//go:embed rules.txt
+var defaultRules []byte
+
+// how many user agents are remembered before the cache is dropped
+const MAX_CACHE_SIZE = 10000
+
+type Agent struct {
+	// the browser name, or the bot name when Bot is true
+	Browser string
+	Version string
+	OS      string
+	// "desktop", "mobile", "tablet", "bot" or "unknown"
+	Device string
+	Bot    bool
+}
+
+type rule_t struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+type Classifier struct {
+	bots     []rule_t
+	browsers []rule_t
+	systems  []rule_t
+	devices  []rule_t
+
+	mutex sync.Mutex
+	cache map[string]Agent
+}
+
+// Parse a rules file. every non empty line that does not start with "#"
+// is "<kind> <name>  <pattern>". the name may have spaces, so it must be
+// separated from the pattern by at least two spaces.
+func Parse(content []byte) (*Classifier, error) {
+	c := Classifier{
+		cache: make(map[string]Agent),
+	}
+
+	for number, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		kind, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+
+		// the name ends at the first run of two or more spaces
+		name, pattern, found := strings.Cut(rest, "  ")
+
+		if !found {
+			return nil, fmt.Errorf("line %d: expected \"<kind> <name>  <pattern>\"", number+1)
+		}
+
+		name = strings.TrimSpace(name)
+		pattern = strings.TrimSpace(pattern)
+
+		re, err := regexp.Compile(pattern)
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pattern: %s", number+1, err.Error())
+		}
+
+		rule := rule_t{name: name, pattern: re}
+
+		switch kind {
+		case "bot":
+			c.bots = append(c.bots, rule)
+		case "browser":
+			c.browsers = append(c.browsers, rule)
+		case "os":
+			c.systems = append(c.systems, rule)
+		case "device":
+			c.devices = append(c.devices, rule)
+		default:
+			return nil, fmt.Errorf("line %d: invalid rule kind \"%s\"", number+1, kind)
+		}
+	}
+
+	return &c, nil
+}
+
+func Load(filePath string) (*Classifier, error) {
+	content, err := os.ReadFile(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := Parse(content)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err.Error())
+	}
+
+	return c, nil
+}
+
+// Default returns a classifier using the rules embedded in the binary
+func Default() *Classifier {
+	c, err := Parse(defaultRules)
+
+	if err != nil {
+		panic("unreacheable: invalid embedded user agent rules: " + err.Error())
+	}
+
+	return c
+}
+
+func find(rules []rule_t, agent string) (string, string, bool) {
+	for _, rule := range rules {
+		matches := rule.pattern.FindStringSubmatch(agent)
+
+		if matches == nil {
+			continue
+		}
+
+		version := ""
+
+		if len(matches) > 1 {
+			version = matches[1]
+		}
+
+		return rule.name, version, true
+	}
+
+	return "", "", false
+}
+
+func (c *Classifier) classify(agent string) Agent {
+	result := Agent{Device: "unknown"}
+
+	if name, version, ok := find(c.bots, agent); ok {
+		result.Browser = name
+		result.Version = version
+		result.Bot = true
+		result.Device = "bot"
+	} else if name, version, ok := find(c.browsers, agent); ok {
+		result.Browser = name
+		result.Version = version
+		result.Device = "desktop"
+	}
+
+	if name, _, ok := find(c.systems, agent); ok {
+		result.OS = name
+	}
+
+	if !result.Bot {
+		if name, _, ok := find(c.devices, agent); ok {
+			result.Device = name
+		}
+	}
+
+	return result
+}
+
+// Classify the user agent. the results are cached since the same few
+// user agents tend to repeat a lot in the logs.
+func (c *Classifier) Classify(agent string) Agent {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if result, ok := c.cache[agent]; ok {
+		return result
+	}
+
+	result := c.classify(agent)
+
+	if len(c.cache) >= MAX_CACHE_SIZE {
+		c.cache = make(map[string]Agent)
+	}
+
+	c.cache[agent] = result
+
+	return result
+}
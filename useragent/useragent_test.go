@@ -0,0 +1,116 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		agent    string
+		expected Agent
+	}{
+		{
+			agent:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			expected: Agent{Browser: "Chrome", Version: "120.0.0.0", OS: "Windows", Device: "desktop"},
+		},
+		{
+			agent:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			expected: Agent{Browser: "Edge", Version: "120.0.2210.91", OS: "Windows", Device: "desktop"},
+		},
+		{
+			agent:    "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			expected: Agent{Browser: "Firefox", Version: "121.0", OS: "Linux", Device: "desktop"},
+		},
+		{
+			agent:    "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			expected: Agent{Browser: "Safari", Version: "17.2", OS: "macOS", Device: "desktop"},
+		},
+		{
+			agent:    "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			expected: Agent{Browser: "Safari", Version: "17.2", OS: "iOS", Device: "mobile"},
+		},
+		{
+			agent:    "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			expected: Agent{Browser: "Chrome", Version: "120.0.6099.119", OS: "iOS", Device: "tablet"},
+		},
+		{
+			agent:    "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			expected: Agent{Browser: "Chrome", Version: "120.0.6099.144", OS: "Android", Device: "mobile"},
+		},
+		{
+			agent:    "Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			expected: Agent{Browser: "Samsung Internet", Version: "23.0", OS: "Android", Device: "mobile"},
+		},
+		{
+			agent:    "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected: Agent{Browser: "Googlebot", Version: "2.1", Device: "bot", Bot: true},
+		},
+		{
+			agent:    "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.71 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected: Agent{Browser: "Googlebot", Version: "2.1", OS: "Android", Device: "bot", Bot: true},
+		},
+		{
+			agent:    "curl/8.4.0",
+			expected: Agent{Browser: "curl", Version: "8.4.0", Device: "bot", Bot: true},
+		},
+		{
+			agent:    "sqlmap/1.7.2#stable (https://sqlmap.org)",
+			expected: Agent{Browser: "sqlmap", Version: "1.7.2", Device: "bot", Bot: true},
+		},
+		{
+			agent:    "python-requests/2.31.0",
+			expected: Agent{Browser: "python-requests", Version: "2.31.0", Device: "bot", Bot: true},
+		},
+		{
+			agent:    "Mozilla/5.0 (compatible; SomeCrawler; +https://example.com)",
+			expected: Agent{Browser: "Bot", Device: "bot", Bot: true},
+		},
+		{
+			agent:    "-",
+			expected: Agent{Device: "unknown"},
+		},
+	}
+
+	c := Default()
+
+	for _, test := range cases {
+		assert.Equal(t, test.expected, c.Classify(test.agent), test.agent)
+	}
+}
+
+func TestClassifyIsCached(t *testing.T) {
+	c := Default()
+
+	agent := "curl/8.4.0"
+
+	c.Classify(agent)
+
+	_, ok := c.cache[agent]
+
+	assert.True(t, ok)
+}
+
+func TestParseCustomRules(t *testing.T) {
+	c, err := Parse([]byte("# comment\n\nbot     Internal Monitor  ^monitor/([\\d.]+)\nbrowser Chrome  Chrome/([\\d.]+)\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, Agent{Browser: "Internal Monitor", Version: "1.2", Device: "bot", Bot: true}, c.Classify("monitor/1.2"))
+}
+
+func TestParseInvalidRules(t *testing.T) {
+	_, err := Parse([]byte("bot Googlebot"))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 1: expected \"<kind> <name>  <pattern>\"", err.Error())
+
+	_, err = Parse([]byte("\nrobot  x  y"))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "line 2: invalid rule kind \"robot\"", err.Error())
+
+	_, err = Parse([]byte("bot  x  ("))
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marcos-venicius/lfi/sender"
+)
+
+const DEFAULT_RETRIES = sender.DEFAULT_RETRIES
+
+var Formats = []string{"json", "slack"}
+
+type Event struct {
+	// "alert" or "log"
+	Kind string `json:"kind"`
+	Time string `json:"time"`
+	// the event as a line of text, which is what slack shows
+	Text   string         `json:"text"`
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+type Options struct {
+	// "json" posts an array of events, "slack" posts {"text": ...} with a line per event
+	Format        string
+	BatchSize     int
+	FlushInterval time.Duration
+	// how many times a batch is sent again, waiting Backoff, 2 * Backoff, 4 * Backoff...
+	Retries int
+	Backoff time.Duration
+	Timeout time.Duration
+	// a directory where the batches that could not be sent are kept, and
+	// sent again once the endpoint is back. they are dropped when empty
+	Spool string
+	// called with the errors of the deliveries, which happen in background
+	OnError func(error)
+}
+
+// Sink delivers events to an http endpoint, in batches, from background
+type Sink struct {
+	options  Options
+	endpoint *sender.Endpoint
+	sender   *sender.Sender[Event]
+}
+
+func Create(url string, options Options) (*Sink, error) {
+	if len(options.Format) == 0 {
+		options.Format = "json"
+	}
+
+	valid := false
+
+	for _, format := range Formats {
+		valid = valid || format == options.Format
+	}
+
+	if !valid {
+		return nil, fmt.Errorf("invalid webhook format \"%s\". expected one of %s", options.Format, strings.Join(Formats, ", "))
+	}
+
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid webhook url \"%s\"", url)
+	}
+
+	if options.OnError == nil {
+		options.OnError = func(error) {}
+	}
+
+	if len(options.Spool) > 0 {
+		if err := os.MkdirAll(options.Spool, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Sink{
+		options:  options,
+		endpoint: sender.CreateEndpoint(url, "webhook", nil, options.Timeout, sender.RetryServerErrors),
+	}
+
+	s.sender = sender.Create(s.endpoint, sender.Options[Event]{
+		BatchSize:     options.BatchSize,
+		FlushInterval: options.FlushInterval,
+		Retries:       options.Retries,
+		Backoff:       options.Backoff,
+		Encode:        s.body,
+		Ready:         s.ready,
+		Failed:        s.failed,
+		OnError:       options.OnError,
+	})
+
+	return s, nil
+}
+
+// Send queues the event without blocking. when the queue is full the
+// event is dropped
+func (s *Sink) Send(event Event) {
+	s.sender.Send(event)
+}
+
+// how many events were dropped because the queue was full
+func (s *Sink) Dropped() int {
+	return s.sender.Dropped()
+}
+
+// Close sends the queued events and waits for them
+func (s *Sink) Close() {
+	s.sender.Close()
+}
+
+func (s *Sink) body(batch []Event) ([]byte, error) {
+	if s.options.Format == "slack" {
+		lines := make([]string, 0, len(batch))
+
+		for _, event := range batch {
+			lines = append(lines, event.Text)
+		}
+
+		return json.Marshal(map[string]string{"text": strings.Join(lines, "\n")})
+	}
+
+	return json.Marshal(batch)
+}
+
+// while older batches are waiting the endpoint is probably down, so the
+// new ones wait with them, in order
+func (s *Sink) ready() error {
+	if !s.resendSpool() {
+		return fmt.Errorf("the webhook is down")
+	}
+
+	return nil
+}
+
+// the batches worth sending again are spooled, the others are dropped
+func (s *Sink) failed(body []byte, events int, retry bool, err error) {
+	if !retry {
+		s.options.OnError(fmt.Errorf("%s, %d events were dropped", err.Error(), events))
+		return
+	}
+
+	s.spool(body, events, err)
+}
+
+// keeps a batch that could not be sent, when there is a spool
+func (s *Sink) spool(body []byte, events int, reason error) {
+	if len(s.options.Spool) == 0 {
+		s.options.OnError(fmt.Errorf("%s, %d events were not sent", reason.Error(), events))
+		return
+	}
+
+	path := filepath.Join(s.options.Spool, fmt.Sprintf("%020d.json", time.Now().UnixNano()))
+
+	if err := os.WriteFile(path, body, 0600); err != nil {
+		s.options.OnError(fmt.Errorf("%s, %d events were not sent: %s", reason.Error(), events, err.Error()))
+		return
+	}
+
+	s.options.OnError(fmt.Errorf("%s, %d events were spooled", reason.Error(), events))
+}
+
+// the spooled batches, oldest first
+func (s *Sink) spooled() []string {
+	if len(s.options.Spool) == 0 {
+		return nil
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(s.options.Spool, "*.json"))
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// sends the spooled batches again, and tells if the spool is empty. the
+// batches rejected by the endpoint are dropped, they would block the spool
+func (s *Sink) resendSpool() bool {
+	for _, path := range s.spooled() {
+		body, err := os.ReadFile(path)
+
+		if err != nil {
+			s.options.OnError(err)
+			return false
+		}
+
+		if retry, err := s.endpoint.Post(body); retry {
+			return false
+		} else if err != nil {
+			s.options.OnError(fmt.Errorf("%s, the spooled batch %s was dropped", err.Error(), filepath.Base(path)))
+		}
+
+		os.Remove(path)
+	}
+
+	return true
+}
@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marcos-venicius/lfi/sender/sendertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// the events received, in order
+func received(t *testing.T, r *sendertest.Receiver) []Event {
+	events := make([]Event, 0)
+
+	for _, body := range r.Bodies() {
+		batch := make([]Event, 0)
+
+		assert.Nil(t, json.Unmarshal(body, &batch))
+
+		events = append(events, batch...)
+	}
+
+	return events
+}
+
+func texts(t *testing.T, r *sendertest.Receiver) []string {
+	texts := []string{}
+
+	for _, e := range received(t, r) {
+		texts = append(texts, e.Text)
+	}
+
+	return texts
+}
+
+func event(text string) Event {
+	return Event{Kind: "log", Time: "2025-03-28T14:00:00Z", Text: text}
+}
+
+func TestJSONFormat(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	s, err := Create(r.URL(), Options{BatchSize: 3, FlushInterval: time.Hour})
+
+	assert.Nil(t, err)
+
+	for _, text := range []string{"a", "b", "c", "d"} {
+		s.Send(event(text))
+	}
+
+	s.Close()
+
+	assert.Equal(t, 2, len(r.Bodies()))
+	assert.Equal(t, event("a"), received(t, r)[0])
+	assert.Equal(t, []string{"a", "b", "c", "d"}, texts(t, r))
+}
+
+func TestSlackFormat(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	s, _ := Create(r.URL(), Options{Format: "slack", BatchSize: 2})
+
+	s.Send(event("first"))
+	s.Send(event("second"))
+	s.Close()
+
+	assert.Equal(t, 1, len(r.Bodies()))
+	assert.JSONEq(t, `{"text": "first\nsecond"}`, string(r.Bodies()[0]))
+}
+
+func TestServerErrorsAreRetried(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	r.Fail(http.StatusInternalServerError, 2)
+
+	errors := []error{}
+	s, _ := Create(r.URL(), Options{BatchSize: 1, Retries: 3, Backoff: time.Millisecond, OnError: func(err error) { errors = append(errors, err) }})
+
+	s.Send(event("a"))
+	s.Close()
+
+	assert.Equal(t, 3, r.Requests())
+	assert.Empty(t, errors)
+}
+
+func TestSpool(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	spool := t.TempDir()
+	errors := []error{}
+
+	r.Fail(http.StatusServiceUnavailable, -1)
+
+	s, _ := Create(r.URL(), Options{BatchSize: 1, Retries: 1, Backoff: time.Millisecond, FlushInterval: time.Hour, Spool: spool, OnError: func(err error) { errors = append(errors, err) }})
+
+	s.Send(event("a"))
+	s.Send(event("b"))
+
+	assert.Eventually(t, func() bool { return len(s.spooled()) == 2 }, time.Second, 5*time.Millisecond)
+
+	r.Recover()
+
+	s.Send(event("c"))
+	s.Close()
+
+	// the spooled events are sent first
+	assert.Equal(t, []string{"a", "b", "c"}, texts(t, r))
+	assert.Equal(t, 2, len(errors))
+
+	files, _ := os.ReadDir(spool)
+
+	assert.Empty(t, files)
+}
+
+func TestDroppedWithoutSpool(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	r.Fail(http.StatusServiceUnavailable, -1)
+
+	errors := []error{}
+	s, _ := Create(r.URL(), Options{BatchSize: 1, Retries: 0, OnError: func(err error) { errors = append(errors, err) }})
+
+	s.Send(event("a"))
+	s.Close()
+
+	assert.Equal(t, 1, len(errors))
+	assert.Equal(t, "webhook answered 503 Service Unavailable, 1 events were not sent", errors[0].Error())
+}
+
+func TestNotRetryable(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	r.Fail(http.StatusUnprocessableEntity, -1)
+
+	spool := t.TempDir()
+	errors := []error{}
+	s, _ := Create(r.URL(), Options{BatchSize: 1, Retries: 3, Backoff: time.Millisecond, Spool: spool, OnError: func(err error) { errors = append(errors, err) }})
+
+	s.Send(event("a"))
+	s.Close()
+
+	// the endpoint would reject it again, so it's neither retried nor spooled
+	assert.Equal(t, 1, r.Requests())
+	assert.Equal(t, 1, len(errors))
+	assert.Equal(t, "webhook answered 422 Unprocessable Entity, 1 events were dropped", errors[0].Error())
+	assert.Empty(t, s.spooled())
+}
+
+func TestRejectedSpool(t *testing.T) {
+	r := sendertest.Create()
+	defer r.Close()
+
+	spool := t.TempDir()
+
+	assert.Nil(t, os.WriteFile(filepath.Join(spool, "00000000000000000001.json"), []byte("not json"), 0600))
+
+	errors := []error{}
+	s, _ := Create(r.URL(), Options{BatchSize: 1, Spool: spool, OnError: func(err error) { errors = append(errors, err) }})
+
+	s.Send(event("a"))
+	s.Close()
+
+	// the rejected batch doesn't hold the new ones back
+	assert.Equal(t, 1, r.Rejected())
+	assert.Equal(t, "a", texts(t, r)[0])
+	assert.Equal(t, 1, len(errors))
+	assert.Equal(t, "webhook answered 400 Bad Request, the spooled batch 00000000000000000001.json was dropped", errors[0].Error())
+	assert.Empty(t, s.spooled())
+}
+
+func TestInvalidOptions(t *testing.T) {
+	_, err := Create("http://localhost", Options{Format: "xml"})
+
+	assert.NotNil(t, err)
+
+	_, err = Create("localhost:8080", Options{})
+
+	assert.NotNil(t, err)
+}